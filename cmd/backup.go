@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	backupsDir         = "backups"
+	backupManifestFile = "manifest.json"
+	backupFilesDir     = "files"
+	backupIDFormat     = "20060102150405"
+)
+
+//upgradeBackup represents a snapshot of the files touched by an upgrade
+type upgradeBackup struct {
+	ID       string    `json:"id"`
+	Template string    `json:"template"`
+	Created  time.Time `json:"created"`
+
+	//files that existed before the upgrade (restored on rollback)
+	Saved []string `json:"saved"`
+
+	//files that didn't exist before the upgrade (deleted on rollback)
+	Added []string `json:"added"`
+
+	//false if the upgrade didn't finish (e.g., it failed part way through)
+	Complete bool `json:"complete"`
+}
+
+func getBackupsDir() string {
+	return filepath.Join(stateDir, backupsDir)
+}
+
+func newUpgradeBackup(template string) *upgradeBackup {
+	now := time.Now()
+	return &upgradeBackup{
+		ID:       now.Format(backupIDFormat),
+		Template: template,
+		Created:  now,
+		Saved:    []string{},
+		Added:    []string{},
+	}
+}
+
+func (backup *upgradeBackup) dir() string {
+	return filepath.Join(getBackupsDir(), backup.ID)
+}
+
+//save snapshots a file before it's changed or deleted by an upgrade. the manifest is
+//written before the file is touched so that a failed upgrade can still be rolled back.
+func (backup *upgradeBackup) save(file string) {
+	key := lockKey(file)
+	if containsString(backup.Saved, key) || containsString(backup.Added, key) {
+		return
+	}
+	if _, err := os.Stat(file); os.IsNotExist(err) {
+		backup.Added = append(backup.Added, key)
+		backup.writeManifest()
+		return
+	}
+	dest := filepath.Join(backup.dir(), backupFilesDir, file)
+	debug(fmt.Sprintf("backing up %s to %s", file, dest))
+	err := os.MkdirAll(filepath.Dir(dest), 0755)
+	check(err)
+	err = copyFile(file, dest)
+	check(err)
+	backup.Saved = append(backup.Saved, key)
+	backup.writeManifest()
+}
+
+func (backup *upgradeBackup) writeManifest() {
+	err := os.MkdirAll(backup.dir(), 0755)
+	check(err)
+	dat, err := json.MarshalIndent(backup, "", "  ")
+	check(err)
+	err = ioutil.WriteFile(filepath.Join(backup.dir(), backupManifestFile), append(dat, '\n'), 0644)
+	check(err)
+	if len(backup.Saved)+len(backup.Added) == 1 {
+		ensureFileContains(".gitignore", []string{filepath.ToSlash(getBackupsDir())})
+	}
+}
+
+//finish marks the backup as complete (if any files were touched)
+func (backup *upgradeBackup) finish() {
+	if len(backup.Saved) == 0 && len(backup.Added) == 0 {
+		return
+	}
+	backup.Complete = true
+	backup.writeManifest()
+	fmt.Println()
+	fmt.Printf("backed up changed files to %s (use `fargate-create rollback` to undo this upgrade)\n", backup.dir())
+}
+
+//restore puts the files back the way they were before the upgrade
+func (backup *upgradeBackup) restore() {
+	for _, key := range backup.Saved {
+		file := filepath.FromSlash(key)
+		fmt.Println("restoring", file)
+		err := os.MkdirAll(filepath.Dir(file), 0755)
+		check(err)
+		err = copyFile(filepath.Join(backup.dir(), backupFilesDir, file), file)
+		check(err)
+	}
+	for _, key := range backup.Added {
+		file := filepath.FromSlash(key)
+		if _, err := os.Stat(file); err == nil {
+			fmt.Println("deleting", file)
+			err = os.Remove(file)
+			check(err)
+		}
+	}
+}
+
+//listBackups returns the available backups, oldest first
+func listBackups() []*upgradeBackup {
+	result := []*upgradeBackup{}
+	dirs, err := ioutil.ReadDir(getBackupsDir())
+	if os.IsNotExist(err) {
+		return result
+	}
+	check(err)
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		dat, err := ioutil.ReadFile(filepath.Join(getBackupsDir(), d.Name(), backupManifestFile))
+		if err != nil {
+			debug("skipping", d.Name(), err)
+			continue
+		}
+		var backup upgradeBackup
+		err = json.Unmarshal(dat, &backup)
+		check(err)
+		result = append(result, &backup)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
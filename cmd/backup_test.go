@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+//runs a test in tmpDir since backups are written relative to the current directory
+func chdirTestCase(t *testing.T) func() {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Chdir(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return func() {
+		os.Chdir(wd)
+	}
+}
+
+func TestBackupRollback(t *testing.T) {
+
+	//arrange
+	teardownTestCase := setupTestCase(t)
+	defer teardownTestCase(t)
+	defer chdirTestCase(t)()
+	ioutil.WriteFile("main.tf", []byte("original"), 0644)
+	ioutil.WriteFile("removed.tf", []byte("removed"), 0644)
+
+	//act
+	//the upgrade fails part way through (without finishing the backup)
+	backup := newUpgradeBackup("git::https://github.com/turnerlabs/terraform-ecs-fargate?ref=v0.5.0")
+	backup.save("main.tf")
+	ioutil.WriteFile("main.tf", []byte("upgraded"), 0644)
+	backup.save("removed.tf")
+	os.Remove("removed.tf")
+	backup.save("added.tf")
+	ioutil.WriteFile("added.tf", []byte("added"), 0644)
+
+	//assert
+	backups := listBackups()
+	if len(backups) != 1 {
+		t.Fatalf("expected: %d; actual: %d", 1, len(backups))
+	}
+	if backups[0].Complete {
+		t.Error("expecting backup to be incomplete")
+	}
+	backups[0].restore()
+	for file, expected := range map[string]string{"main.tf": "original", "removed.tf": "removed"} {
+		dat, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(dat) != expected {
+			t.Errorf("expected: %s; actual: %s", expected, string(dat))
+		}
+	}
+	if _, err := os.Stat("added.tf"); !os.IsNotExist(err) {
+		t.Error("expecting added.tf to be deleted")
+	}
+}
+
+func TestBackupFinish(t *testing.T) {
+
+	//arrange
+	teardownTestCase := setupTestCase(t)
+	defer teardownTestCase(t)
+	defer chdirTestCase(t)()
+	ioutil.WriteFile("main.tf", []byte("original"), 0644)
+
+	//act
+	backup := newUpgradeBackup("git::https://github.com/turnerlabs/terraform-ecs-fargate?ref=v0.5.0")
+	backup.save("main.tf")
+	backup.save("main.tf")
+	backup.finish()
+
+	//assert
+	backups := listBackups()
+	if len(backups) != 1 {
+		t.Fatalf("expected: %d; actual: %d", 1, len(backups))
+	}
+	if !backups[0].Complete {
+		t.Error("expecting backup to be complete")
+	}
+	if len(backups[0].Saved) != 1 {
+		t.Errorf("expected: %d; actual: %d", 1, len(backups[0].Saved))
+	}
+	dat, _ := ioutil.ReadFile(".gitignore")
+	if string(dat) != getBackupsDir()+"\n" {
+		t.Error("expecting backups to be ignored", string(dat))
+	}
+}
+
+func TestBackupUnchanged(t *testing.T) {
+	teardownTestCase := setupTestCase(t)
+	defer teardownTestCase(t)
+	defer chdirTestCase(t)()
+
+	newUpgradeBackup("").finish()
+
+	if len(listBackups()) != 0 {
+		t.Error("not expecting a backup")
+	}
+}
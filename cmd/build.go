@@ -1,10 +1,12 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/turnerlabs/fargate-create/cmd/build"
@@ -14,53 +16,413 @@ var buildCmd = &cobra.Command{
 	Use:   "build",
 	Short: "Scaffold out artifacts for various build systems",
 	Long:  "Scaffold out artifacts for various build systems",
-	Args:  cobra.ExactArgs(1),
+	Args:  buildArgsValidator,
 	Run:   doBuild,
 	Example: `
 fargate-create build local
 fargate-create build circleciv2
 fargate-create build githubactions
+fargate-create build awscodebuild
+fargate-create build gitlabci
+fargate-create build bitbucket
+fargate-create build jenkins
+fargate-create build azurepipelines
+fargate-create build buildkite
+
+# customize a provider's templates
+fargate-create build export-template circleciv2
+
+# use a plugin (fargate-create-build-foo)
+fargate-create build foo
+
+# promote the same image through multiple environments
+fargate-create build githubactions --envs dev,qa,prod
+
+# use OIDC role assumption rather than static keys
+fargate-create build githubactions --oidc --terraform --repo my-org/my-app
+
+# trigger on main and release branches, tag images with the version in package.json
+fargate-create build gitlabci --branch main --branch release --versioning package.json
+
+# build and push a multi-arch image locally and deploy it
+fargate-create build local --platform linux/amd64,linux/arm64 --deploy
+
+# plan terraform changes to iac/env/<env> on pull requests and apply them on merge
+fargate-create build githubactions --iac
+
+# scan images for vulnerabilities (failing on critical ones) and generate an SBOM before they're pushed
+fargate-create build circleciv2 --scan --scan-severity CRITICAL
+
+# build a Dockerfile in a subdirectory with build args
+fargate-create build circleciv2 --dockerfile docker/Dockerfile --docker-context ./app --build-arg NODE_ENV=production
+
+# replace an existing .gitlab-ci.yml rather than merging the generated jobs into it
+fargate-create build gitlabci --overwrite
+
+# check existing CI config files (of all providers or one) against the providers' schemas
+fargate-create build --validate
+fargate-create build githubactions --validate
+`,
+}
+
+var (
+	buildOIDC         bool
+	buildRoleARN      string
+	buildTerraform    bool
+	buildRepository   string
+	buildEnvs         []string
+	buildTemplatesDir string
+	buildBranches     []string
+	buildVersioning   string
+	buildVersionFile  string
+	buildDockerfile   string
+	buildDockerCtx    string
+	buildArgs         []string
+	buildPlatforms    []string
+	buildDeploy       bool
+	buildIaC          bool
+	buildScan         bool
+	buildScanSeverity string
+	buildOverwrite    bool
+	buildValidate     bool
+)
+
+var buildExportTemplateCmd = &cobra.Command{
+	Use:   "export-template <provider>",
+	Short: "Write a build provider's built-in templates out for customization",
+	Long:  "Write a build provider's built-in templates out for customization",
+	Args:  cobra.ExactArgs(1),
+	Run:   doExportTemplate,
+	Example: `
+fargate-create build export-template circleciv2
+fargate-create build export-template githubactions --templates-dir ./ci-templates
 `,
 }
 
 func init() {
+	buildCmd.PersistentFlags().StringVar(&buildTemplatesDir, "templates-dir", "", "directory of customized provider templates, <templates-dir>/<provider>/ (default ~/.config/fargate-create/build)")
+	buildCmd.AddCommand(buildExportTemplateCmd)
+	buildCmd.Flags().BoolVar(&buildOIDC, "oidc", false, "use OIDC role assumption rather than static keys (githubactions only)")
+	buildCmd.Flags().StringVar(&buildRoleARN, "role-arn", "", "IAM role to assume when using --oidc (default arn:aws:iam::<account>:role/<app>-<env>-github-actions)")
+	buildCmd.Flags().BoolVar(&buildTerraform, "terraform", false, "generate terraform for the OIDC provider and role (requires --oidc)")
+	buildCmd.Flags().StringVar(&buildRepository, "repo", "", "source repository (e.g., my-org/my-app) allowed to assume the OIDC role")
+	buildCmd.Flags().StringSliceVar(&buildEnvs, "envs", []string{}, "generate a single pipeline that promotes an image through these installed environments, in order (e.g., dev,qa,prod)")
+	buildCmd.Flags().StringSliceVar(&buildBranches, "branch", []string{}, "branches that trigger the pipeline (default develop)")
+	buildCmd.Flags().StringVar(&buildVersioning, "versioning", "", "image versioning strategy: "+strings.Join(build.VersioningStrategies, ", ")+" (default static)")
+	buildCmd.Flags().StringVar(&buildVersionFile, "version-file", "", "file containing the version when using --versioning semver (default VERSION)")
+	buildCmd.Flags().StringVar(&buildDockerfile, "dockerfile", "", "path to the Dockerfile (default Dockerfile in the docker context)")
+	buildCmd.Flags().StringVar(&buildDockerCtx, "docker-context", "", "docker build context directory (default .)")
+	buildCmd.Flags().StringArrayVar(&buildArgs, "build-arg", []string{}, "docker build arg (e.g., FOO=bar), can be specified multiple times")
+	buildCmd.Flags().StringSliceVar(&buildPlatforms, "platform", []string{}, "build a multi-arch image for these platforms using docker buildx, e.g., linux/amd64,linux/arm64 (local only)")
+	buildCmd.Flags().BoolVar(&buildDeploy, "deploy", false, "deploy the image using the fargate cli after it's pushed (local only)")
+	buildCmd.Flags().BoolVar(&buildIaC, "iac", false, "generate pipelines that plan the environment's terraform on pull requests and apply it on merge (githubactions and gitlabci)")
+	buildCmd.Flags().BoolVar(&buildScan, "scan", false, "scan images for vulnerabilities (trivy) and generate an SBOM (syft) before they're pushed")
+	buildCmd.Flags().StringVar(&buildScanSeverity, "scan-severity", "", "lowest vulnerability severity that fails the scan: "+strings.Join(build.ScanSeverities, ", ")+" (default HIGH)")
+	buildCmd.PersistentFlags().BoolVar(&buildOverwrite, "overwrite", false, "overwrite existing CI config files rather than merging the generated jobs into them")
+	buildCmd.Flags().BoolVar(&buildValidate, "validate", false, "validate existing CI config files against the build providers' schemas rather than generating them")
 	rootCmd.AddCommand(buildCmd)
 }
 
+//the provider is optional when validating
+func buildArgsValidator(cmd *cobra.Command, args []string) error {
+	if buildValidate {
+		return cobra.MaximumNArgs(1)(cmd, args)
+	}
+	return cobra.ExactArgs(1)(cmd, args)
+}
+
 func doBuild(cmd *cobra.Command, args []string) {
 
+	if buildValidate {
+		provider := ""
+		if len(args) > 0 {
+			provider = args[0]
+		}
+		if !validateBuildArtifacts(provider) {
+			os.Exit(1)
+		}
+		return
+	}
+
 	//load build provider
 	providerString := args[0]
-	provider, err := build.GetProvider(providerString)
+	if (buildTerraform || buildRoleARN != "") && !buildOIDC {
+		check(errors.New("--terraform and --role-arn require --oidc"))
+	}
+	options := build.Options{
+		OIDC:              buildOIDC,
+		RoleARN:           buildRoleARN,
+		Terraform:         buildTerraform,
+		Repository:        buildRepository,
+		InfrastructureDir: targetDir,
+		Branches:          buildBranches,
+		Versioning:        buildVersioning,
+		VersionFile:       buildVersionFile,
+		Dockerfile:        buildDockerfile,
+		DockerContext:     buildDockerCtx,
+		BuildArgs:         buildArgs,
+		Platforms:         buildPlatforms,
+		Deploy:            buildDeploy,
+		IaC:               buildIaC,
+		Scan:              buildScan,
+		ScanSeverity:      buildScanSeverity,
+	}
+	if buildScanSeverity != "" && !buildScan {
+		check(errors.New("--scan-severity requires --scan"))
+	}
+	if buildVersionFile != "" && buildVersioning != build.VersioningSemverFile {
+		check(errors.New("--version-file requires --versioning " + build.VersioningSemverFile))
+	}
+	check(build.ValidateOptions(options))
+	if buildIaC {
+		options.Backends = getBuildBackends(buildEnvs)
+	}
+
+	if buildTemplatesDir == "" {
+		buildTemplatesDir = build.TemplatesDir()
+	}
+
+	//prefer a build provider supplied by the template,
+	//then customized provider templates (single environment only)
+	provider, err := getTemplateBuildProvider(providerString, options)
 	check(err)
+	if provider == nil && len(buildEnvs) == 0 {
+		override, err := build.GetTemplateOverride(buildTemplatesDir, providerString, options)
+		check(err)
+		if override != nil {
+			provider = override
+			fmt.Println("using customized provider templates: " + filepath.Join(buildTemplatesDir, providerString))
+		}
+	}
+	if provider == nil {
+		provider, err = build.GetProviderWithOptions(providerString, options)
+		check(err)
+	}
 
 	//get artifacts
-	artifacts, err := provider.ProvideArtifacts(context)
+	var artifacts []*build.Artifact
+	if len(buildEnvs) > 0 {
+		promotionProvider, ok := provider.(build.PromotionProvider)
+		if !ok {
+			check(errors.New("build provider does not support --envs: " + providerString))
+		}
+		contexts := getBuildContexts(buildEnvs)
+		for _, c := range contexts {
+			check(build.ValidateContext(c))
+		}
+		artifacts, err = promotionProvider.ProvidePromotionArtifacts(contexts)
+	} else {
+		check(build.ValidateContext(context))
+		artifacts, err = provider.ProvideArtifacts(context)
+	}
 	check(err)
 
-	//write artifacts to file system
+	//add terraform plan/apply pipelines
+	if buildIaC {
+		infrastructureProvider, ok := provider.(build.InfrastructureProvider)
+		if !ok {
+			check(errors.New("build provider does not support --iac: " + providerString))
+		}
+		contexts := []build.Context{context}
+		if len(buildEnvs) > 0 {
+			contexts = getBuildContexts(buildEnvs)
+		}
+		infrastructureArtifacts, err := infrastructureProvider.ProvideInfrastructureArtifacts(contexts)
+		check(err)
+		artifacts = append(artifacts, infrastructureArtifacts...)
+	}
+
+	//catch broken templates before anything is written
+	err = build.ValidateArtifacts(artifacts)
+	if err != nil {
+		check(fmt.Errorf("generated build artifact is invalid: %v", err))
+	}
+
+	writeArtifacts(artifacts)
+}
+
+//validates the existing CI config files of a provider (or all providers),
+//returning whether they're all valid
+func validateBuildArtifacts(provider string) bool {
+	patterns, err := build.ValidationPatterns(provider)
+	check(err)
+	found := 0
+	valid := true
+	for _, pattern := range patterns {
+		files, err := filepath.Glob(pattern)
+		check(err)
+		for _, file := range files {
+			found++
+			contents, err := ioutil.ReadFile(file)
+			check(err)
+			err = build.ValidateArtifact(&build.Artifact{
+				FilePath:     filepath.ToSlash(file),
+				FileContents: string(contents),
+			})
+			if err != nil {
+				fmt.Println(err)
+				valid = false
+				continue
+			}
+			fmt.Println(file + " is valid")
+		}
+	}
+	if found == 0 {
+		fmt.Println("no CI config files found")
+	}
+	return valid
+}
+
+//writes artifacts to the file system. existing files are shown the changes and prompted
+//before they're merged into (YAML) or overwritten.
+func writeArtifacts(artifacts []*build.Artifact) {
 	if artifacts != nil {
 		for _, artifact := range artifacts {
 			//create directories if needed
 			dirs := filepath.Dir(artifact.FilePath)
-			err = os.MkdirAll(dirs, os.ModePerm)
+			err := os.MkdirAll(dirs, os.ModePerm)
 			check(err)
 
-			if _, err := os.Stat(artifact.FilePath); err == nil {
+			if existing, err := ioutil.ReadFile(artifact.FilePath); err == nil {
 				//exists
-				fmt.Print(artifact.FilePath + " already exists. Overwrite? ")
+				contents, action := getArtifactChanges(artifact, string(existing))
+				if contents == string(existing) {
+					fmt.Println(artifact.FilePath + " is up to date")
+					continue
+				}
+				fmt.Println()
+				fmt.Println(artifact.FilePath + " already exists. Changes:")
+				printDiff(os.Stdout, string(existing), contents)
+				err = build.ValidateArtifact(&build.Artifact{FilePath: artifact.FilePath, FileContents: contents})
+				if err != nil {
+					fmt.Println("warning: the result is invalid,", err)
+				}
+				fmt.Printf("%s %s? ", action, artifact.FilePath)
 				if askForConfirmation() {
-					err = ioutil.WriteFile(artifact.FilePath, []byte(artifact.FileContents), artifact.FileMode)
+					err = ioutil.WriteFile(artifact.FilePath, []byte(contents), artifact.FileMode)
 					fmt.Println("wrote " + artifact.FilePath)
 					check(err)
 				}
 			} else {
 				//doesn't exist
-				err = ioutil.WriteFile(artifact.FilePath, []byte(artifact.FileContents), artifact.FileMode)
+				contents := artifact.FileContents
+				if artifact.Merge && !buildOverwrite {
+					//record the owned entries so that they can be updated later
+					contents, err = build.MergeYAML("", artifact)
+					check(err)
+				}
+				err = ioutil.WriteFile(artifact.FilePath, []byte(contents), artifact.FileMode)
 				fmt.Println("wrote " + artifact.FilePath)
 				check(err)
 			}
 		}
 	}
+}
 
+//returns the contents that an existing file would be replaced with and a description of the action,
+//merging the fargate-create owned entries of YAML artifacts into the existing file (unless --overwrite)
+func getArtifactChanges(artifact *build.Artifact, existing string) (string, string) {
+	if !artifact.Merge || buildOverwrite {
+		return artifact.FileContents, "Overwrite"
+	}
+	merged, err := build.MergeYAML(existing, artifact)
+	if err != nil {
+		fmt.Println("unable to merge, the file will be overwritten:", err)
+		return artifact.FileContents, "Overwrite"
+	}
+	return merged, "Merge changes into"
+}
+
+func doExportTemplate(cmd *cobra.Command, args []string) {
+	providerString := strings.ToLower(args[0])
+	provider, err := build.GetProvider(providerString)
+	check(err)
+	exporter, ok := provider.(build.TemplateExporter)
+	if !ok {
+		check(errors.New("build provider does not have templates to export: " + providerString))
+	}
+	if buildTemplatesDir == "" {
+		buildTemplatesDir = build.TemplatesDir()
+	}
+
+	//write the provider's templates to <templates-dir>/<provider>/
+	artifacts := exporter.ExportTemplates()
+	for _, artifact := range artifacts {
+		artifact.FilePath = filepath.Join(buildTemplatesDir, providerString, artifact.FilePath)
+	}
+	writeArtifacts(artifacts)
+
+	fmt.Println()
+	fmt.Printf("customize the templates in %s and they'll be used by: fargate-create build %s\n",
+		filepath.Join(buildTemplatesDir, providerString), providerString)
+}
+
+//getBuildContexts returns the contexts of installed environments
+func getBuildContexts(environments []string) []build.Context {
+	result := []build.Context{}
+	for _, environment := range environments {
+		dir := filepath.Join(targetDir, envDir, environment)
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			check(fmt.Errorf("environment not found: %s", dir))
+		}
+		envContext, _ := loadEnvironmentContext(dir)
+		result = append(result, envContext)
+	}
+	return result
+}
+
+//getBuildBackends returns the terraform backend configurations of the
+//environments (or the current one), read from their scaffolded main.tf
+func getBuildBackends(environments []string) map[string]build.Backend {
+	if len(environments) == 0 {
+		environments = []string{context.Env}
+	}
+	result := map[string]build.Backend{}
+	for _, environment := range environments {
+		mainTfFile := filepath.Join(targetDir, envDir, environment, "main.tf")
+		fileBits, err := ioutil.ReadFile(mainTfFile)
+		check(err)
+		backend := parseTerraformBackend(string(fileBits))
+		if len(backend) == 0 {
+			check(fmt.Errorf("terraform backend not found: %s", mainTfFile))
+		}
+		debug("terraform backend:", environment, backend)
+		result[environment] = build.Backend{
+			Bucket: backend["bucket"],
+			Key:    backend["key"],
+			Region: backend["region"],
+		}
+	}
+	return result
+}
+
+//getTemplateBuildProvider returns the build provider supplied by the
+//installed environment's template (if any), which is preferred over the built-in ones
+func getTemplateBuildProvider(provider string, options build.Options) (build.Provider, error) {
+	envTargetDir := filepath.Join(targetDir, envDir, context.Env)
+	config := loadTemplateConfig(envTargetDir)
+	if config == nil || config.Build == nil {
+		return nil, nil
+	}
+	dir := config.Build.Dir
+	if dir == "" {
+		dir = defaultBuildDir
+	}
+	providerDir := filepath.Join(envTargetDir, dir, strings.ToLower(provider))
+	if info, err := os.Stat(providerDir); err != nil || !info.IsDir() {
+		debug("template does not supply build provider:", providerDir)
+		return nil, nil
+	}
+
+	//template providers render a single environment
+	if len(buildEnvs) > 0 {
+		fmt.Printf("the build provider supplied by the template (%s) doesn't support --envs, using the built-in provider\n", providerDir)
+		return nil, nil
+	}
+	result, err := build.GetTemplateOverride(filepath.Join(envTargetDir, dir), provider, options)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Println("using build provider supplied by template: " + providerDir)
+	return result, nil
 }
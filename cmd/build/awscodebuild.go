@@ -1,47 +1,77 @@
 package build
 
-//AWSCodeBuild represents a Github Actions build provider
-type AWSCodeBuild struct{}
+//AWSCodeBuild represents an AWS CodeBuild build provider
+type AWSCodeBuild struct {
+	Options Options
+}
 
 //ProvideArtifacts is the Provider implementation
 func (provider AWSCodeBuild) ProvideArtifacts(context Context) ([]*Artifact, error) {
 	artifacts := []*Artifact{}
-	artifacts = append(artifacts, createArtifact("buildspec.yml", getAWSBuildspecYAML(context)))
+	artifacts = append(artifacts, createYAMLArtifact("buildspec.yml", getAWSBuildspecYAML(context, provider.Options), "phases"))
 	return artifacts, nil
 }
 
-func getAWSBuildspecYAML(context Context) string {
-	contextTemplate := getContextTemplate(context)
+//ExportTemplates is the TemplateExporter implementation
+func (provider AWSCodeBuild) ExportTemplates() []*Artifact {
+	return []*Artifact{
+		createArtifact("buildspec.yml", awsBuildspecTemplate),
+	}
+}
+
+//awsBuildspecTemplate is the template for buildspec.yml
+const awsBuildspecTemplate = `version: 0.2
+{{- if .NeedsGitTags }}
+env:
+  git-credential-helper: yes
+{{- end }}
+phases:
+  install:
+    runtime-versions:
+      docker: 18
+    commands:
+      - nohup /usr/bin/dockerd --host=unix:///var/run/docker.sock --host=tcp://127.0.0.1:2375 --storage-driver=overlay2&
+  pre_build:
+    commands:
+      - export FARGATE_CLUSTER={{ .App }}-{{ .Env }}
+      - export FARGATE_SERVICE={{ .App }}-{{ .Env }}
+{{- if .IsScheduledTask }}
+      - export FARGATE_TASK={{ .App }}-{{ .Env }}
+      - export FARGATE_RULE={{ .App }}-{{ .Env }}
+{{- end }}
+      - export REPO={{ .Account }}.dkr.ecr.{{ .Region }}.amazonaws.com/{{ .App }}
+
+      # build image:tag
+{{- if .NeedsGitTags }}
+      - git fetch --tags --unshallow || git fetch --tags
+{{- end }}
+      - export VERSION={{ .Version }}
+      - export BUILD=$(echo ${CODEBUILD_BUILD_ID} | cut -d ":" -f 2)
+      - export BRANCH=$(echo ${CODEBUILD_WEBHOOK_HEAD_REF} | cut -d "/" -f 3)
+      - export IMAGE=${REPO}:${VERSION}-${BRANCH}.${BUILD}
 
-	textTemplate := `version: 0.2
-  phases:
-    install:
-      runtime-versions:
-        docker: 18
-      commands:
-        - nohup /usr/bin/dockerd --host=unix:///var/run/docker.sock --host=tcp://127.0.0.1:2375 --storage-driver=overlay2&
-    pre_build:
-      commands:
-        - export FARGATE_CLUSTER={{ .App }}-{{ .Env }}
-        - export FARGATE_SERVICE={{ .App }}-{{ .Env }}
-        - export REPO={{ .Account }}.dkr.ecr.{{ .Region }}.amazonaws.com/{{ .App }}
-  
-        # build image:tag      
-        - export VERSION=0.1.0
-        # - export VERSION=$(jq -r .version < package.json)
-        - export BUILD=$(echo ${CODEBUILD_BUILD_ID} | cut -d ":" -f 2)
-        - export BRANCH=$(echo ${CODEBUILD_WEBHOOK_HEAD_REF} | cut -d "/" -f 3)
-        - export IMAGE=${REPO}:${VERSION}-${BRANCH}.${BUILD}
-  
-        # login to ECR registry
-        - login=$(aws ecr get-login --no-include-email) && eval "$login"
-    build:
-      commands:
-        - docker build -t ${IMAGE} .
-        - docker push ${IMAGE}
-    post_build:
-      commands:
-        - fargate service deploy -i ${IMAGE}`
+      # login to ECR registry
+      - login=$(aws ecr get-login --no-include-email) && eval "$login"
+  build:
+    on-failure: ABORT
+    commands:
+      - {{ .DockerBuildCommand "${IMAGE}" }}
+{{- if .Scan }}
+      - {{ .ScanCommand "${IMAGE}" }}
+      - {{ .SBOMCommand "${IMAGE}" }}
+{{- end }}
+      - docker push ${IMAGE}
+  post_build:
+    commands:
+      # post_build runs even if the build (e.g., the scan) fails
+      - '[ "${CODEBUILD_BUILD_SUCCEEDING}" = "1" ] || (echo "the build failed, skipping the deploy" && exit 1)'
+      - {{ .DeployCommand "${IMAGE}" }}
+{{- if .Scan }}
+artifacts:
+  files:
+    - {{ .SBOMFile }}
+{{- end }}`
 
-	return applyTemplate(textTemplate, contextTemplate)
+func getAWSBuildspecYAML(context Context, options Options) string {
+	return applyTemplate(awsBuildspecTemplate, getContextTemplate(context, options))
 }
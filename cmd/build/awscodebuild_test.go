@@ -43,3 +43,33 @@ func TestProvider_AWSCodeBuild(t *testing.T) {
 		t.Error("expecting", cluster)
 	}
 }
+
+func TestProvider_AWSCodeBuildScanFailure(t *testing.T) {
+	provider, err := GetProviderWithOptions("awscodebuild", Options{Scan: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	artifacts, err := provider.ProvideArtifacts(mockContext{App: "my-app", Env: "dev", Account: "123456789", Region: "us-west-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	yaml := artifacts[0].FileContents
+	t.Log(yaml)
+
+	//post_build (the deploy) runs even when the build (the scan) fails
+	guard := `- '[ "${CODEBUILD_BUILD_SUCCEEDING}" = "1" ] || (echo "the build failed, skipping the deploy" && exit 1)'`
+	postBuild := strings.Split(yaml, "post_build:")[1]
+	if !strings.Contains(postBuild, guard) {
+		t.Error("expecting", guard)
+	}
+	if strings.Index(postBuild, guard) > strings.Index(postBuild, "fargate service deploy") {
+		t.Error("expecting the guard before the deploy")
+	}
+	if !strings.Contains(yaml, "  build:\n    on-failure: ABORT\n") {
+		t.Error("expecting build phase to abort on failure")
+	}
+	err = ValidateArtifact(artifacts[0])
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,104 @@
+package build
+
+import "fmt"
+
+//AzurePipelines represents an Azure Pipelines build provider
+type AzurePipelines struct {
+	Options Options
+}
+
+//ProvideArtifacts is the Provider implementation
+func (provider AzurePipelines) ProvideArtifacts(context Context) ([]*Artifact, error) {
+	artifacts := []*Artifact{}
+	artifacts = append(artifacts, createYAMLArtifact("azure-pipelines.yml", getAzurePipelinesYAML(context, provider.Options), "variables"))
+
+	fmt.Println()
+	fmt.Println(`Be sure to add the following secret variables to your Azure pipeline:
+  AWS_ACCESS_KEY_ID (terraform state show aws_iam_access_key.cicd_keys)
+  AWS_SECRET_ACCESS_KEY (terraform state show aws_iam_access_key.cicd_keys)`)
+	fmt.Println()
+
+	return artifacts, nil
+}
+
+//ExportTemplates is the TemplateExporter implementation
+func (provider AzurePipelines) ExportTemplates() []*Artifact {
+	return []*Artifact{
+		createArtifact("azure-pipelines.yml", azurePipelinesTemplate),
+	}
+}
+
+//azurePipelinesTemplate is the template for azure-pipelines.yml
+const azurePipelinesTemplate = `trigger:
+  branches:
+    include:
+{{- range .Branches }}
+      - {{ . }}
+{{- end }}
+
+pool:
+  vmImage: ubuntu-latest
+
+variables:
+  REPO: {{ .Account }}.dkr.ecr.{{ .Region }}.amazonaws.com/{{ .App }}
+  AWS_DEFAULT_REGION: {{ .Region }}
+  FARGATE_CLUSTER: {{ .App }}-{{ .Env }}
+  FARGATE_SERVICE: {{ .App }}-{{ .Env }}
+{{- if .IsScheduledTask }}
+  FARGATE_TASK: {{ .App }}-{{ .Env }}
+  FARGATE_RULE: {{ .App }}-{{ .Env }}
+{{- end }}
+
+steps:
+{{- if .NeedsGitTags }}
+  - checkout: self
+    fetchDepth: 0
+    fetchTags: true
+{{- end }}
+  - script: |
+      VERSION={{ .Version }}
+      BUILD=$(Build.BuildId)
+      if [ "$(Build.SourceBranchName)" != "master" ]; then
+        BUILD=$(Build.SourceBranchName).$(Build.BuildId)
+      fi
+      echo "##vso[task.setvariable variable=IMAGE]$(REPO):${VERSION}-${BUILD}"
+    displayName: Set docker image
+
+  - script: aws ecr get-login-password | docker login --username AWS --password-stdin {{ .Account }}.dkr.ecr.{{ .Region }}.amazonaws.com
+    displayName: Login to registry
+    env:
+      AWS_ACCESS_KEY_ID: $(AWS_ACCESS_KEY_ID)
+      AWS_SECRET_ACCESS_KEY: $(AWS_SECRET_ACCESS_KEY)
+
+  - script: {{ .DockerBuildCommand "$(IMAGE)" }}
+    displayName: Build app image
+{{- if .Scan }}
+
+  - script: {{ .ScanCommand "$(IMAGE)" }}
+    displayName: Scan app image for vulnerabilities
+
+  - script: {{ .SBOMCommand "$(IMAGE)" }}
+    displayName: Generate SBOM
+
+  - publish: {{ .SBOMFile }}
+    artifact: sbom
+    displayName: Publish SBOM
+{{- end }}
+
+  - script: docker push $(IMAGE)
+    displayName: Push app image to registry
+
+  - script: >
+      docker run --rm
+      -e AWS_ACCESS_KEY_ID -e AWS_SECRET_ACCESS_KEY -e AWS_DEFAULT_REGION
+      -e FARGATE_CLUSTER -e FARGATE_SERVICE -e FARGATE_TASK -e FARGATE_RULE -e IMAGE
+      quay.io/turner/fargate-cicd
+      sh -c '{{ .DeployCommand "${IMAGE}" }}'
+    displayName: Deploy
+    env:
+      AWS_ACCESS_KEY_ID: $(AWS_ACCESS_KEY_ID)
+      AWS_SECRET_ACCESS_KEY: $(AWS_SECRET_ACCESS_KEY)`
+
+func getAzurePipelinesYAML(context Context, options Options) string {
+	return applyTemplate(azurePipelinesTemplate, getContextTemplate(context, options))
+}
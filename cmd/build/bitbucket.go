@@ -0,0 +1,91 @@
+package build
+
+import "fmt"
+
+//Bitbucket represents a Bitbucket Pipelines build provider
+type Bitbucket struct {
+	Options Options
+}
+
+//ProvideArtifacts is the Provider implementation
+func (provider Bitbucket) ProvideArtifacts(context Context) ([]*Artifact, error) {
+	artifacts := []*Artifact{}
+	artifacts = append(artifacts, createYAMLArtifact("bitbucket-pipelines.yml", getBitbucketPipelinesYAML(context, provider.Options), "pipelines.branches"))
+
+	fmt.Println()
+	fmt.Printf(`Be sure to add the following repository variables to your Bitbucket repository:
+  AWS_ACCESS_KEY_ID (terraform state show aws_iam_access_key.cicd_keys)
+  AWS_SECRET_ACCESS_KEY (terraform state show aws_iam_access_key.cicd_keys, secured)
+
+and create a "%s" deployment environment (Repository settings > Deployments)
+`, context.GetEnvironment())
+	fmt.Println()
+
+	return artifacts, nil
+}
+
+//ExportTemplates is the TemplateExporter implementation
+func (provider Bitbucket) ExportTemplates() []*Artifact {
+	return []*Artifact{
+		createArtifact("bitbucket-pipelines.yml", bitbucketPipelinesTemplate),
+	}
+}
+
+//bitbucketPipelinesTemplate is the template for bitbucket-pipelines.yml
+const bitbucketPipelinesTemplate = `image: quay.io/turner/fargate-cicd
+{{- if .NeedsGitTags }}
+
+clone:
+  depth: full
+{{- end }}
+
+definitions:
+  steps:
+    - step: &build
+        name: Build and push image
+        services:
+          - docker
+        script:
+          - export REPO={{ .Account }}.dkr.ecr.{{ .Region }}.amazonaws.com/{{ .App }}
+          - export VERSION={{ .Version }}
+          - export BRANCH=$(echo ${BITBUCKET_BRANCH} | tr '/' '-')
+          - export IMAGE=${REPO}:${VERSION}-${BRANCH}.${BITBUCKET_COMMIT:0:7}
+          - export AWS_DEFAULT_REGION={{ .Region }}
+          - {{ .DockerBuildCommand "${IMAGE}" }}
+{{- if .Scan }}
+          # bitbucket only allows mounting the clone directory so images are scanned from an archive
+          - docker save -o image.tar ${IMAGE}
+          - docker run --rm -v ${BITBUCKET_CLONE_DIR}:/src aquasec/trivy:latest image --no-progress --exit-code 1 --severity {{ .ScanSeverities }} --input /src/image.tar
+          - docker run --rm -v ${BITBUCKET_CLONE_DIR}:/src anchore/syft:latest docker-archive:/src/image.tar -o spdx-json > {{ .SBOMFile }}
+          - rm image.tar
+{{- end }}
+          - aws ecr get-login-password | docker login --username AWS --password-stdin {{ .Account }}.dkr.ecr.{{ .Region }}.amazonaws.com
+          - docker push ${IMAGE}
+          - echo "export IMAGE=${IMAGE}" > image.env
+        artifacts:
+          - image.env
+{{- if .Scan }}
+          - {{ .SBOMFile }}
+{{- end }}
+
+pipelines:
+  branches:
+    '{{ .BranchPattern }}':
+      - step: *build
+      - step:
+          name: Deploy to {{ .Env }}
+          deployment: {{ .Env }}
+          script:
+            - source image.env
+            - export AWS_DEFAULT_REGION={{ .Region }}
+            - export FARGATE_CLUSTER={{ .App }}-{{ .Env }}
+            - export FARGATE_SERVICE={{ .App }}-{{ .Env }}
+{{- if .IsScheduledTask }}
+            - export FARGATE_TASK={{ .App }}-{{ .Env }}
+            - export FARGATE_RULE={{ .App }}-{{ .Env }}
+{{- end }}
+            - {{ .DeployCommand "${IMAGE}" }}`
+
+func getBitbucketPipelinesYAML(context Context, options Options) string {
+	return applyTemplate(bitbucketPipelinesTemplate, getContextTemplate(context, options))
+}
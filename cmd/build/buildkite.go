@@ -0,0 +1,77 @@
+package build
+
+import "fmt"
+
+//Buildkite represents a Buildkite build provider
+type Buildkite struct {
+	Options Options
+}
+
+//ProvideArtifacts is the Provider implementation
+func (provider Buildkite) ProvideArtifacts(context Context) ([]*Artifact, error) {
+	artifacts := []*Artifact{}
+	artifacts = append(artifacts, createYAMLArtifact(".buildkite/pipeline.yml", getBuildkiteYAML(context, provider.Options), "env"))
+
+	fmt.Println()
+	fmt.Println(`Be sure that your Buildkite agents have docker, the aws cli, and the fargate cli installed
+along with the following environment variables (e.g., using an agent environment hook):
+  AWS_ACCESS_KEY_ID (terraform state show aws_iam_access_key.cicd_keys)
+  AWS_SECRET_ACCESS_KEY (terraform state show aws_iam_access_key.cicd_keys)`)
+	fmt.Println()
+
+	return artifacts, nil
+}
+
+//ExportTemplates is the TemplateExporter implementation
+func (provider Buildkite) ExportTemplates() []*Artifact {
+	return []*Artifact{
+		createArtifact(".buildkite/pipeline.yml", buildkiteTemplate),
+	}
+}
+
+//buildkiteTemplate is the template for .buildkite/pipeline.yml
+//note that $$ defers interpolation until the step runs
+const buildkiteTemplate = `env:
+  REPO: {{ .Account }}.dkr.ecr.{{ .Region }}.amazonaws.com/{{ .App }}
+  AWS_DEFAULT_REGION: {{ .Region }}
+  FARGATE_CLUSTER: {{ .App }}-{{ .Env }}
+  FARGATE_SERVICE: {{ .App }}-{{ .Env }}
+{{- if .IsScheduledTask }}
+  FARGATE_TASK: {{ .App }}-{{ .Env }}
+  FARGATE_RULE: {{ .App }}-{{ .Env }}
+{{- end }}
+
+steps:
+  - label: ":docker: Build and push image"
+    branches: {{ .BranchList }}
+    commands:
+{{- if .NeedsGitTags }}
+      - git fetch --tags --force
+{{- end }}
+      - export VERSION={{ .Version }}
+      - BUILD=$${BUILDKITE_BUILD_NUMBER}
+      - if [ "$${BUILDKITE_BRANCH}" != "master" ]; then BUILD=$${BUILDKITE_BRANCH}.$${BUILDKITE_BUILD_NUMBER}; fi
+      - export IMAGE=$${REPO}:$${VERSION}-$${BUILD}
+      - buildkite-agent meta-data set image $${IMAGE}
+      - aws ecr get-login-password | docker login --username AWS --password-stdin {{ .Account }}.dkr.ecr.{{ .Region }}.amazonaws.com
+      - {{ .DockerBuildCommand "$${IMAGE}" }}
+{{- if .Scan }}
+      - {{ .ScanCommand "$${IMAGE}" }}
+      - {{ .SBOMCommand "$${IMAGE}" }}
+{{- end }}
+      - docker push $${IMAGE}
+{{- if .Scan }}
+    artifact_paths: {{ .SBOMFile }}
+{{- end }}
+
+  - wait
+
+  - label: ":rocket: Deploy to {{ .Env }}"
+    branches: {{ .BranchList }}
+    commands:
+      - export IMAGE=$$(buildkite-agent meta-data get image)
+      - {{ .DeployCommand "$${IMAGE}" }}`
+
+func getBuildkiteYAML(context Context, options Options) string {
+	return applyTemplate(buildkiteTemplate, getContextTemplate(context, options))
+}
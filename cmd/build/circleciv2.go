@@ -3,14 +3,19 @@ package build
 import "fmt"
 
 //CircleCIv2 represents a circle ci v2 build provider
-type CircleCIv2 struct{}
+type CircleCIv2 struct {
+	Options Options
+}
+
+//circleCIv2MergeKeys are the config keys whose children are merged individually
+var circleCIv2MergeKeys = []string{"references", "jobs", "workflows"}
 
 //ProvideArtifacts is the Provider implementation
 func (provider CircleCIv2) ProvideArtifacts(context Context) ([]*Artifact, error) {
 
 	artifacts := []*Artifact{}
-	artifacts = append(artifacts, createArtifact(".circleci/config.yml", getCircleCIv2YAML()))
-	artifacts = append(artifacts, createArtifact(".circleci/config.env", getConfigEnv(context)))
+	artifacts = append(artifacts, createYAMLArtifact(".circleci/config.yml", getCircleCIv2YAML(context, provider.Options), circleCIv2MergeKeys...))
+	artifacts = append(artifacts, createArtifact(".circleci/config.env", getConfigEnv(context, provider.Options)))
 
 	fmt.Println()
 	fmt.Println(`Be sure to supply the following environment variables in your Circle CI build:
@@ -22,9 +27,16 @@ func (provider CircleCIv2) ProvideArtifacts(context Context) ([]*Artifact, error
 	return artifacts, nil
 }
 
-func getCircleCIv2YAML() string {
-	return `
-version: 2
+//ExportTemplates is the TemplateExporter implementation
+func (provider CircleCIv2) ExportTemplates() []*Artifact {
+	return []*Artifact{
+		createArtifact(".circleci/config.yml", circleCIv2Template),
+		createArtifact(".circleci/config.env", circleCIv2ConfigEnvTemplate),
+	}
+}
+
+//circleCIv2Template is the template for .circleci/config.yml
+const circleCIv2Template = `version: 2
 jobs:
   build:
     docker:
@@ -33,6 +45,9 @@ jobs:
       VAR: .circleci/config.env
     steps:
       - checkout
+{{- if .NeedsGitTags }}
+      - run: git fetch --tags --force
+{{- end }}
       - setup_remote_docker:
           version: 18.06.0-ce
       - run:
@@ -53,22 +68,175 @@ jobs:
           command: login=$(aws ecr get-login --no-include-email) && eval "$login"
       - run:
           name: Build app image
-          command: . ${VAR}; docker build -t ${IMAGE} .
+          command: . ${VAR}; {{ .DockerBuildCommand "${IMAGE}" }}
+{{- if .Scan }}
+      - run:
+          name: Scan app image for vulnerabilities
+          command: . ${VAR}; {{ .ScanCommand "${IMAGE}" }}
+      - run:
+          name: Generate SBOM
+          command: . ${VAR}; {{ .SBOMCommand "${IMAGE}" }}
+      - store_artifacts:
+          path: {{ .SBOMFile }}
+{{- end }}
       - run:
           name: Push app image to registry
           command: . ${VAR}; docker push ${IMAGE}
       - run:
           name: Deploy
-          command: . ${VAR}; fargate service deploy -i ${IMAGE}`
-}
+          command: . ${VAR}; {{ .DeployCommand "${IMAGE}" }}
+workflows:
+  version: 2
+  build-deploy:
+    jobs:
+      - build:
+          filters:
+            branches:
+              only:
+{{- range .Branches }}
+                - {{ . }}
+{{- end }}`
 
-func getConfigEnv(context Context) string {
-	contextTemplate := getContextTemplate(context)
+func getCircleCIv2YAML(context Context, options Options) string {
+	return applyTemplate(circleCIv2Template, getContextTemplate(context, options))
+}
 
-	textTemplate := `export FARGATE_CLUSTER="{{ .App }}-{{ .Env }}"
+//circleCIv2ConfigEnvTemplate is the template for .circleci/config.env
+const circleCIv2ConfigEnvTemplate = `export FARGATE_CLUSTER="{{ .App }}-{{ .Env }}"
 export FARGATE_SERVICE="{{ .App }}-{{ .Env }}"
+{{- if .IsScheduledTask }}
+export FARGATE_TASK="{{ .App }}-{{ .Env }}"
+export FARGATE_RULE="{{ .App }}-{{ .Env }}"
+{{- end }}
 export REPO="{{ .Account }}.dkr.ecr.{{ .Region }}.amazonaws.com/{{ .App }}"
-export VERSION="0.1.0"
+export VERSION="{{ .Version }}"
 `
-	return applyTemplate(textTemplate, contextTemplate)
+
+func getConfigEnv(context Context, options Options) string {
+	return applyTemplate(circleCIv2ConfigEnvTemplate, getContextTemplate(context, options))
+}
+
+//ProvidePromotionArtifacts is the PromotionProvider implementation
+func (provider CircleCIv2) ProvidePromotionArtifacts(contexts []Context) ([]*Artifact, error) {
+	promotion, err := getPromotionTemplate(contexts, provider.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	artifacts := []*Artifact{}
+	artifacts = append(artifacts, createYAMLArtifact(".circleci/config.yml", getCircleCIv2PromotionYAML(promotion), circleCIv2MergeKeys...))
+
+	fmt.Println()
+	fmt.Println("Be sure to create the following Circle CI contexts (Organization Settings > Contexts):")
+	for _, stage := range promotion.Stages {
+		fmt.Printf("  %s-%s\n", stage.App, stage.Env)
+	}
+	fmt.Println(`with the following environment variables:
+  AWS_ACCESS_KEY_ID (terraform state show aws_iam_access_key.cicd_keys)
+  AWS_SECRET_ACCESS_KEY (terraform state show aws_iam_access_key.cicd_keys)`)
+	fmt.Printf("Images are pushed to the %s registry so other environments need permission to pull from it.\n", promotion.Env)
+	fmt.Println()
+
+	return artifacts, nil
+}
+
+func getCircleCIv2PromotionYAML(promotion promotionTemplate) string {
+	textTemplate := `version: 2.1
+
+references:
+  filters: &filters
+    branches:
+      only:
+{{- range .Branches }}
+        - {{ . }}
+{{- end }}
+    tags:
+      only: /^v.*/
+
+jobs:
+  build:
+    docker:
+      - image: quay.io/turner/fargate-cicd
+    environment:
+      REPO: {{ .Account }}.dkr.ecr.{{ .Region }}.amazonaws.com/{{ .App }}
+      AWS_DEFAULT_REGION: {{ .Region }}
+    steps:
+      - checkout
+{{- if .NeedsGitTags }}
+      - run: git fetch --tags --force
+{{- end }}
+      - setup_remote_docker
+      - run:
+          name: Build and push app image
+          command: |
+            VERSION={{ .Version }}
+            IMAGE=${REPO}:${VERSION}-${CIRCLE_TAG:-${CIRCLE_BRANCH}.${CIRCLE_BUILD_NUM}}
+            {{ .DockerBuildCommand "${IMAGE}" }}
+{{- if .Scan }}
+            {{ .ScanCommand "${IMAGE}" }}
+            {{ .SBOMCommand "${IMAGE}" }}
+{{- end }}
+            aws ecr get-login-password | docker login --username AWS --password-stdin {{ .Account }}.dkr.ecr.{{ .Region }}.amazonaws.com
+            docker push ${IMAGE}
+            DIGEST=$(docker inspect --format '{{"{{index .RepoDigests 0}}"}}' ${IMAGE})
+            if [ -z "${DIGEST}" ]; then echo "unable to get the digest of ${IMAGE}"; exit 1; fi
+            mkdir -p workspace
+            echo "export IMAGE=${DIGEST}" > workspace/image.env
+{{- if .Scan }}
+      - store_artifacts:
+          path: {{ .SBOMFile }}
+{{- end }}
+      - persist_to_workspace:
+          root: workspace
+          paths:
+            - image.env
+
+  deploy:
+    parameters:
+      env:
+        type: string
+      region:
+        type: string
+    docker:
+      - image: quay.io/turner/fargate-cicd
+    environment:
+      AWS_DEFAULT_REGION: << parameters.region >>
+      FARGATE_CLUSTER: {{ .App }}-<< parameters.env >>
+      FARGATE_SERVICE: {{ .App }}-<< parameters.env >>
+{{- if .IsScheduledTask }}
+      FARGATE_TASK: {{ .App }}-<< parameters.env >>
+      FARGATE_RULE: {{ .App }}-<< parameters.env >>
+{{- end }}
+    steps:
+      - attach_workspace:
+          at: workspace
+      - run:
+          name: Deploy
+          command: . workspace/image.env; {{ .DeployCommand "${IMAGE}" }}
+
+workflows:
+  promote:
+    jobs:
+      - build:
+          context: {{ .App }}-{{ .Env }}
+          filters: *filters
+{{- range .Stages }}
+{{- if .Previous }}
+      - approve-{{ .Env }}:
+          type: approval
+          requires:
+            - deploy-{{ .Previous }}
+          filters: *filters
+{{- end }}
+      - deploy:
+          name: deploy-{{ .Env }}
+          env: {{ .Env }}
+          region: {{ .Region }}
+          context: {{ .App }}-{{ .Env }}
+          requires:
+            - {{ if .Previous }}approve-{{ .Env }}{{ else }}build{{ end }}
+          filters: *filters
+{{- end }}`
+
+	return applyTemplate(textTemplate, promotion)
 }
@@ -31,6 +31,15 @@ func TestProvider_CircleCIv2(t *testing.T) {
 	if artifacts[0].FilePath != ".circleci/config.yml" {
 		t.Fail()
 	}
+	//the build job is run by a workflow so that it's run alongside the workflows of an existing config
+	workflow := "workflows:\n  version: 2\n  build-deploy:\n    jobs:\n      - build:\n"
+	if !strings.Contains(artifacts[0].FileContents, workflow) {
+		t.Error("expecting", workflow)
+	}
+	err = ValidateArtifact(artifacts[0])
+	if err != nil {
+		t.Error(err)
+	}
 
 	configEnv := artifacts[1].FileContents
 	t.Log(configEnv)
@@ -53,3 +62,40 @@ func TestProvider_CircleCIv2(t *testing.T) {
 		t.Error("not expecting", lines[4])
 	}
 }
+
+func TestProvider_CircleCIv2Promotion(t *testing.T) {
+	provider, err := GetProvider("circleciv2")
+	if err != nil {
+		t.Fail()
+	}
+	artifacts, err := provider.(PromotionProvider).ProvidePromotionArtifacts(mockPromotionContexts())
+	if err != nil {
+		t.Fail()
+	}
+
+	yaml := artifacts[0].FileContents
+	t.Log(yaml)
+
+	expected := []string{
+		"approve-qa:",
+		"approve-prod:",
+		"name: deploy-prod",
+		"context: my-app-prod",
+		"region: us-west-2",
+	}
+	for _, e := range expected {
+		if !strings.Contains(yaml, e) {
+			t.Error("expecting", e)
+		}
+	}
+	if strings.Contains(yaml, "approve-dev:") {
+		t.Error("not expecting approve-dev")
+	}
+}
+
+func TestProvider_PromotionNoEnvironments(t *testing.T) {
+	_, err := CircleCIv2{}.ProvidePromotionArtifacts([]Context{})
+	if err == nil {
+		t.Error("expecting error")
+	}
+}
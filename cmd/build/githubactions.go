@@ -1,14 +1,26 @@
 package build
 
-import "fmt"
+import (
+	"fmt"
+	"path/filepath"
+)
 
 //GithubActions represents a Github Actions build provider
-type GithubActions struct{}
+type GithubActions struct {
+	Options Options
+}
+
+//githubActionsMergeKeys are the workflow keys whose children are merged individually
+var githubActionsMergeKeys = []string{"permissions", "env", "jobs"}
 
 //ProvideArtifacts is the Provider implementation
 func (provider GithubActions) ProvideArtifacts(context Context) ([]*Artifact, error) {
+	if provider.Options.OIDC {
+		return provider.provideOIDCArtifacts(context)
+	}
+
 	artifacts := []*Artifact{}
-	artifacts = append(artifacts, createArtifact(fmt.Sprintf(".github/workflows/%s.yml", context.GetEnvironment()), getGithubActionsYAML(context)))
+	artifacts = append(artifacts, createYAMLArtifact(fmt.Sprintf(".github/workflows/%s.yml", context.GetEnvironment()), getGithubActionsYAML(context, provider.Options), githubActionsMergeKeys...))
 
 	fmt.Println()
 	fmt.Println(`Be sure to add the following secrets to your Github repository:
@@ -19,26 +31,37 @@ func (provider GithubActions) ProvideArtifacts(context Context) ([]*Artifact, er
 	return artifacts, nil
 }
 
-func getGithubActionsYAML(context Context) string {
-	contextTemplate := getContextTemplate(context)
+//ExportTemplates is the TemplateExporter implementation
+func (provider GithubActions) ExportTemplates() []*Artifact {
+	return []*Artifact{
+		createArtifact(".github/workflows/{{ .Env }}.yml", githubActionsTemplate),
+	}
+}
 
-	textTemplate := `name: {{ .Env }}
+//githubActionsTemplate is the template for .github/workflows/<env>.yml
+const githubActionsTemplate = `name: {{ .Env }}
 on:
   push:
     branches:
-      - develop
+{{- range .Branches }}
+      - {{ . }}
+{{- end }}
 jobs:
   cicd:
-    name: Deploy develop branch to {{ .Env }} environment
+    name: Deploy to {{ .Env }} environment
     runs-on: ubuntu-latest
     steps:
       - uses: actions/checkout@master
+{{- if .NeedsGitTags }}
+        with:
+          fetch-depth: 0
+{{- end }}
 
       - name: Set docker image
         env:
           REPO: {{ .Account }}.dkr.ecr.{{ .Region }}.amazonaws.com/{{ .App }}
-          VERSION: 0.1.0
         run: |
+          VERSION={{ .Version }}
           BRANCH=$(echo $GITHUB_REF | cut -d "/" -f 3)
           SHA_SHORT=$(echo $GITHUB_SHA | head -c7)
           echo "export IMAGE=$REPO:$VERSION-$BRANCH.$SHA_SHORT" >> ./env
@@ -46,7 +69,21 @@ jobs:
       - name: Build image
         uses: turnerlabs/fargate-cicd-action@master
         with:
-          args: . ./env; docker build -t $IMAGE .
+          args: . ./env; {{ .DockerBuildCommand "$IMAGE" }}
+{{- if .Scan }}
+
+      - name: Scan image for vulnerabilities
+        run: . ./env; {{ .ScanCommand "$IMAGE" }}
+
+      - name: Generate SBOM
+        run: . ./env; {{ .SBOMCommand "$IMAGE" }}
+
+      - name: Upload SBOM
+        uses: actions/upload-artifact@v4
+        with:
+          name: sbom
+          path: {{ .SBOMFile }}
+{{- end }}
 
       - name: Login to ECR
         uses: turnerlabs/fargate-cicd-action@master
@@ -70,8 +107,514 @@ jobs:
           AWS_SECRET_ACCESS_KEY: ${{"{{ secrets.AWS_SECRET_ACCESS_KEY }}"}}
           FARGATE_CLUSTER: {{ .App }}-{{ .Env }}
           FARGATE_SERVICE: {{ .App }}-{{ .Env }}
+{{- if .IsScheduledTask }}
+          FARGATE_TASK: {{ .App }}-{{ .Env }}
+          FARGATE_RULE: {{ .App }}-{{ .Env }}
+{{- end }}
         with:
-          args: . ./env; fargate service deploy -i $IMAGE`
+          args: . ./env; {{ .DeployCommand "$IMAGE" }}`
+
+func getGithubActionsYAML(context Context, options Options) string {
+	return applyTemplate(githubActionsTemplate, getContextTemplate(context, options))
+}
+
+func (provider GithubActions) provideOIDCArtifacts(context Context) ([]*Artifact, error) {
+	roleARN := provider.Options.RoleARN
+	if roleARN == "" {
+		roleARN = getGithubActionsRoleARN(context)
+	}
+
+	artifacts := []*Artifact{}
+	artifacts = append(artifacts, createYAMLArtifact(fmt.Sprintf(".github/workflows/%s.yml", context.GetEnvironment()), getGithubActionsOIDCYAML(context, provider.Options, roleARN), githubActionsMergeKeys...))
+
+	if provider.Options.Terraform {
+		infraDir := provider.Options.InfrastructureDir
+		if infraDir == "" {
+			infraDir = "iac"
+		}
+		artifacts = append(artifacts,
+			createArtifact(filepath.Join(infraDir, "base", "github-oidc.tf"), getGithubActionsOIDCProviderTF()),
+			createArtifact(filepath.Join(infraDir, "env", context.GetEnvironment(), "github-oidc.tf"), getGithubActionsOIDCRoleTF(provider.Options.Repository, getContextTemplate(context, provider.Options).IsScheduledTask())),
+		)
+
+		fmt.Println()
+		fmt.Println(`Apply the generated terraform in both base and env to create the Github OIDC provider and the
+role that your workflow assumes. The role only trusts the repository specified by the github_repo variable.`)
+		fmt.Println()
+	} else {
+		fmt.Println()
+		fmt.Println("Be sure that the following role exists and trusts your Github repository using OIDC:\n  " + roleARN)
+		fmt.Println()
+	}
+
+	return artifacts, nil
+}
+
+func getGithubActionsRoleARN(context Context) string {
+	return fmt.Sprintf("arn:aws:iam::%s:role/%s-%s-github-actions", context.GetAccount(), context.GetApp(), context.GetEnvironment())
+}
+
+func getGithubActionsOIDCYAML(context Context, options Options, roleARN string) string {
+	contextTemplate := struct {
+		contextTemplate
+		RoleARN string
+	}{
+		contextTemplate: getContextTemplate(context, options),
+		RoleARN:         roleARN,
+	}
+
+	textTemplate := `name: {{ .Env }}
+on:
+  push:
+    branches:
+{{- range .Branches }}
+      - {{ . }}
+{{- end }}
+permissions:
+  id-token: write
+  contents: read
+jobs:
+  cicd:
+    name: Deploy to {{ .Env }} environment
+    runs-on: ubuntu-latest
+    steps:
+      - uses: actions/checkout@v4
+{{- if .NeedsGitTags }}
+        with:
+          fetch-depth: 0
+{{- end }}
+
+      - name: Configure AWS credentials
+        uses: aws-actions/configure-aws-credentials@v4
+        with:
+          role-to-assume: {{ .RoleARN }}
+          aws-region: {{ .Region }}
+
+      - name: Login to ECR
+        uses: aws-actions/amazon-ecr-login@v2
+
+      - name: Set docker image
+        env:
+          REPO: {{ .Account }}.dkr.ecr.{{ .Region }}.amazonaws.com/{{ .App }}
+        run: |
+          VERSION={{ .Version }}
+          SHA_SHORT=$(echo $GITHUB_SHA | head -c7)
+          echo "IMAGE=$REPO:$VERSION-$GITHUB_REF_NAME.$SHA_SHORT" >> $GITHUB_ENV
+
+      - name: Build image
+        run: {{ .DockerBuildCommand "$IMAGE" }}
+{{- if .Scan }}
+
+      - name: Scan image for vulnerabilities
+        run: {{ .ScanCommand "$IMAGE" }}
+
+      - name: Generate SBOM
+        run: {{ .SBOMCommand "$IMAGE" }}
+
+      - name: Upload SBOM
+        uses: actions/upload-artifact@v4
+        with:
+          name: sbom
+          path: {{ .SBOMFile }}
+{{- end }}
+
+      - name: Push image to ECR
+        run: docker push $IMAGE
+
+      - name: Deploy image to fargate
+        env:
+          FARGATE_CLUSTER: {{ .App }}-{{ .Env }}
+          FARGATE_SERVICE: {{ .App }}-{{ .Env }}
+{{- if .IsScheduledTask }}
+          FARGATE_TASK: {{ .App }}-{{ .Env }}
+          FARGATE_RULE: {{ .App }}-{{ .Env }}
+{{- end }}
+        run: >
+          docker run --rm
+          -e AWS_ACCESS_KEY_ID -e AWS_SECRET_ACCESS_KEY -e AWS_SESSION_TOKEN -e AWS_DEFAULT_REGION
+          -e FARGATE_CLUSTER -e FARGATE_SERVICE -e FARGATE_TASK -e FARGATE_RULE -e IMAGE
+          quay.io/turner/fargate-cicd
+          sh -c '{{ .DeployCommand "${IMAGE}" }}'`
+
+	return applyTemplate(textTemplate, contextTemplate)
+}
+
+func getGithubActionsOIDCProviderTF() string {
+	return `# the github oidc identity provider (one per account)
+resource "aws_iam_openid_connect_provider" "github" {
+  url             = "https://token.actions.githubusercontent.com"
+  client_id_list  = ["sts.amazonaws.com"]
+  thumbprint_list = ["6938fd4d98bab03faadb97b34396831e3780aea1"]
+}
+`
+}
+
+func getGithubActionsOIDCRoleTF(repository string, scheduledTask bool) string {
+	repoVariable := `variable "github_repo" {
+  description = "the github repository (org/repo) that is allowed to assume the role"
+}`
+	if repository != "" {
+		repoVariable = fmt.Sprintf(`variable "github_repo" {
+  description = "the github repository (org/repo) that is allowed to assume the role"
+  default     = "%s"
+}`, repository)
+	}
+
+	//scheduled tasks are deployed by pointing their rule at a new task definition
+	eventsStatement := ""
+	if scheduledTask {
+		eventsStatement = `
+  statement {
+    actions = [
+      "events:DescribeRule",
+      "events:ListTargetsByRule",
+      "events:PutTargets",
+    ]
+    resources = ["arn:aws:events:*:${data.aws_caller_identity.github.account_id}:rule/${var.app}-${var.environment}"]
+  }
+`
+	}
+
+	return repoVariable + `
+
+data "aws_caller_identity" "github" {}
+
+data "aws_iam_openid_connect_provider" "github" {
+  url = "https://token.actions.githubusercontent.com"
+}
+
+# the role assumed by github actions using oidc
+resource "aws_iam_role" "github_actions" {
+  name               = "${var.app}-${var.environment}-github-actions"
+  assume_role_policy = data.aws_iam_policy_document.github_actions_assume_role.json
+}
+
+data "aws_iam_policy_document" "github_actions_assume_role" {
+  statement {
+    actions = ["sts:AssumeRoleWithWebIdentity"]
+
+    principals {
+      type        = "Federated"
+      identifiers = [data.aws_iam_openid_connect_provider.github.arn]
+    }
+
+    condition {
+      test     = "StringEquals"
+      variable = "token.actions.githubusercontent.com:aud"
+      values   = ["sts.amazonaws.com"]
+    }
+
+    condition {
+      test     = "StringLike"
+      variable = "token.actions.githubusercontent.com:sub"
+      values   = ["repo:${var.github_repo}:*"]
+    }
+  }
+}
+
+# allow pushing images and deploying to fargate
+resource "aws_iam_role_policy" "github_actions" {
+  name   = "${var.app}-${var.environment}-github-actions"
+  role   = aws_iam_role.github_actions.id
+  policy = data.aws_iam_policy_document.github_actions.json
+}
+
+data "aws_iam_policy_document" "github_actions" {
+  statement {
+    actions   = ["ecr:GetAuthorizationToken"]
+    resources = ["*"]
+  }
+
+  statement {
+    actions = [
+      "ecr:BatchCheckLayerAvailability",
+      "ecr:BatchGetImage",
+      "ecr:CompleteLayerUpload",
+      "ecr:GetDownloadUrlForLayer",
+      "ecr:InitiateLayerUpload",
+      "ecr:PutImage",
+      "ecr:UploadLayerPart",
+    ]
+    resources = ["arn:aws:ecr:*:${data.aws_caller_identity.github.account_id}:repository/${var.app}"]
+  }
+
+  statement {
+    actions = [
+      "ecs:DescribeServices",
+      "ecs:DescribeTaskDefinition",
+      "ecs:DescribeTasks",
+      "ecs:ListTasks",
+      "ecs:RegisterTaskDefinition",
+      "ecs:UpdateService",
+    ]
+    resources = ["*"]
+  }
+
+  statement {
+    actions   = ["iam:PassRole"]
+    resources = ["arn:aws:iam::${data.aws_caller_identity.github.account_id}:role/${var.app}-${var.environment}-*"]
+  }
+` + eventsStatement + `}
+`
+}
+
+//ProvidePromotionArtifacts is the PromotionProvider implementation
+func (provider GithubActions) ProvidePromotionArtifacts(contexts []Context) ([]*Artifact, error) {
+	promotion, err := getPromotionTemplate(contexts, provider.Options)
+	if err != nil {
+		return nil, err
+	}
+	if provider.Options.OIDC {
+		for i, context := range contexts {
+			promotion.Stages[i].RoleARN = provider.Options.RoleARN
+			if promotion.Stages[i].RoleARN == "" {
+				promotion.Stages[i].RoleARN = getGithubActionsRoleARN(context)
+			}
+		}
+	}
+
+	artifacts := []*Artifact{}
+	artifacts = append(artifacts, createYAMLArtifact(fmt.Sprintf(".github/workflows/%s.yml", promotion.App), getGithubActionsPromotionYAML(promotion), githubActionsMergeKeys...))
+
+	fmt.Println()
+	fmt.Println("Be sure to create the following environments in your Github repository (Settings > Environments):")
+	for i, stage := range promotion.Stages {
+		gate := ""
+		if i > 0 {
+			gate = " (add required reviewers to gate the promotion)"
+		}
+		fmt.Printf("  %s%s\n", stage.Env, gate)
+	}
+	if provider.Options.OIDC {
+		fmt.Println("and that the role for each environment trusts your Github repository using OIDC.")
+	} else {
+		fmt.Println(`and add the following secrets to each environment:
+  AWS_ACCESS_KEY_ID (terraform state show aws_iam_access_key.cicd_keys)
+  AWS_SECRET_ACCESS_KEY (terraform state show aws_iam_access_key.cicd_keys)`)
+	}
+	fmt.Printf("Images are pushed to the %s registry so other environments need permission to pull from it.\n", promotion.Env)
+	fmt.Println()
+
+	return artifacts, nil
+}
+
+func getGithubActionsPromotionYAML(promotion promotionTemplate) string {
+	textTemplate := `{{ define "credentials" }}      - name: Configure AWS credentials
+        uses: aws-actions/configure-aws-credentials@v4
+        with:
+{{- if .RoleARN }}
+          role-to-assume: {{ .RoleARN }}
+{{- else }}
+          aws-access-key-id: ${{"{{ secrets.AWS_ACCESS_KEY_ID }}"}}
+          aws-secret-access-key: ${{"{{ secrets.AWS_SECRET_ACCESS_KEY }}"}}
+{{- end }}
+          aws-region: {{ .Region }}
+          mask-aws-account-id: false
+{{ end }}name: {{ .App }}
+on:
+  push:
+    branches:
+{{- range .Branches }}
+      - {{ . }}
+{{- end }}
+    tags:
+      - v*
+{{- with index .Stages 0 }}{{ if .RoleARN }}
+permissions:
+  id-token: write
+  contents: read
+{{- end }}{{ end }}
+jobs:
+  build:
+    name: Build and push image
+    runs-on: ubuntu-latest
+    environment: {{ .Env }}
+    outputs:
+      image: ${{"{{ steps.push.outputs.image }}"}}
+    steps:
+      - uses: actions/checkout@v4
+{{- if .NeedsGitTags }}
+        with:
+          fetch-depth: 0
+{{- end }}
+
+{{ template "credentials" index .Stages 0 }}
+      - name: Login to ECR
+        uses: aws-actions/amazon-ecr-login@v2
+
+      - name: Build image
+        env:
+          REPO: {{ .Account }}.dkr.ecr.{{ .Region }}.amazonaws.com/{{ .App }}
+        run: |
+          VERSION={{ .Version }}
+          SHA_SHORT=$(echo $GITHUB_SHA | head -c7)
+          IMAGE=$REPO:$VERSION-$GITHUB_REF_NAME.$SHA_SHORT
+          echo "IMAGE=$IMAGE" >> $GITHUB_ENV
+          echo "REPO=$REPO" >> $GITHUB_ENV
+          {{ .DockerBuildCommand "$IMAGE" }}
+{{- if .Scan }}
+
+      - name: Scan image for vulnerabilities
+        run: {{ .ScanCommand "$IMAGE" }}
+
+      - name: Generate SBOM
+        run: {{ .SBOMCommand "$IMAGE" }}
+
+      - name: Upload SBOM
+        uses: actions/upload-artifact@v4
+        with:
+          name: sbom
+          path: {{ .SBOMFile }}
+{{- end }}
+
+      - name: Push image to ECR
+        id: push
+        run: |
+          docker push $IMAGE
+          DIGEST=$(docker inspect --format '{{"{{index .RepoDigests 0}}"}}' $IMAGE)
+          if [ -z "$DIGEST" ]; then echo "unable to get the digest of $IMAGE"; exit 1; fi
+          echo "image=$DIGEST" >> $GITHUB_OUTPUT
+{{ range .Stages }}
+  deploy-{{ .Env }}:
+    name: Deploy to {{ .Env }}
+    needs: [build{{ if .Previous }}, deploy-{{ .Previous }}{{ end }}]
+    runs-on: ubuntu-latest
+    environment: {{ .Env }}
+    steps:
+{{ template "credentials" . }}
+      - name: Deploy image to fargate
+        env:
+          IMAGE: ${{"{{ needs.build.outputs.image }}"}}
+          FARGATE_CLUSTER: {{ .App }}-{{ .Env }}
+          FARGATE_SERVICE: {{ .App }}-{{ .Env }}
+{{- if .IsScheduledTask }}
+          FARGATE_TASK: {{ .App }}-{{ .Env }}
+          FARGATE_RULE: {{ .App }}-{{ .Env }}
+{{- end }}
+        run: >
+          docker run --rm
+          -e AWS_ACCESS_KEY_ID -e AWS_SECRET_ACCESS_KEY -e AWS_SESSION_TOKEN -e AWS_DEFAULT_REGION
+          -e FARGATE_CLUSTER -e FARGATE_SERVICE -e FARGATE_TASK -e FARGATE_RULE -e IMAGE
+          quay.io/turner/fargate-cicd
+          sh -c '{{ .DeployCommand "${IMAGE}" }}'
+{{ end }}`
+
+	return applyTemplate(textTemplate, promotion)
+}
+
+//ProvideInfrastructureArtifacts is the InfrastructureProvider implementation
+func (provider GithubActions) ProvideInfrastructureArtifacts(contexts []Context) ([]*Artifact, error) {
+	artifacts := []*Artifact{}
+	for _, context := range contexts {
+		roleARN := ""
+		if provider.Options.OIDC {
+			roleARN = provider.Options.RoleARN
+			if roleARN == "" {
+				roleARN = getGithubActionsRoleARN(context)
+			}
+		}
+		yaml := getGithubActionsIaCYAML(context, provider.Options, roleARN)
+		artifacts = append(artifacts, createYAMLArtifact(fmt.Sprintf(".github/workflows/%s-iac.yml", context.GetEnvironment()), yaml, githubActionsMergeKeys...))
+	}
+
+	fmt.Println()
+	fmt.Println("Be sure to create the following environments in your Github repository (Settings > Environments)")
+	fmt.Println("and add required reviewers to approve terraform changes before they're applied:")
+	for _, context := range contexts {
+		fmt.Printf("  %s-iac\n", context.GetEnvironment())
+	}
+	if provider.Options.OIDC {
+		fmt.Println("The role assumed by the workflows needs permission to manage the environment's resources and terraform state.")
+	} else {
+		fmt.Println(`and add the following secrets (credentials that can manage the environment's resources and terraform state):
+  IAC_AWS_ACCESS_KEY_ID
+  IAC_AWS_SECRET_ACCESS_KEY`)
+	}
+	fmt.Println()
+
+	return artifacts, nil
+}
+
+func getGithubActionsIaCYAML(context Context, options Options, roleARN string) string {
+	contextTemplate := struct {
+		contextTemplate
+		RoleARN string
+	}{
+		contextTemplate: getContextTemplate(context, options),
+		RoleARN:         roleARN,
+	}
+
+	textTemplate := `{{ define "setup" }}      - uses: actions/checkout@v4
+
+      - name: Configure AWS credentials
+        uses: aws-actions/configure-aws-credentials@v4
+        with:
+{{- if .RoleARN }}
+          role-to-assume: {{ .RoleARN }}
+{{- else }}
+          aws-access-key-id: ${{"{{ secrets.IAC_AWS_ACCESS_KEY_ID }}"}}
+          aws-secret-access-key: ${{"{{ secrets.IAC_AWS_SECRET_ACCESS_KEY }}"}}
+{{- end }}
+          aws-region: {{ .Region }}
+
+      - uses: hashicorp/setup-terraform@v3
+        with:
+          terraform_wrapper: false
+
+      - name: Terraform init
+        run: terraform init -input=false {{ .BackendConfig }}
+{{ end }}name: {{ .Env }}-iac
+on:
+  pull_request:
+    paths:
+      - {{ .EnvironmentDir }}/**
+  push:
+    branches:
+{{- range .Branches }}
+      - {{ . }}
+{{- end }}
+    paths:
+      - {{ .EnvironmentDir }}/**
+permissions:
+{{- if .RoleARN }}
+  id-token: write
+{{- end }}
+  contents: read
+defaults:
+  run:
+    working-directory: {{ .EnvironmentDir }}
+jobs:
+  plan:
+    name: Plan {{ .Env }} infrastructure
+    runs-on: ubuntu-latest
+    steps:
+{{ template "setup" . }}
+      - name: Terraform plan
+        run: terraform plan -input=false -var aws_profile= -out tfplan
+
+      - name: Upload plan
+        if: github.event_name == 'push'
+        uses: actions/upload-artifact@v4
+        with:
+          name: tfplan
+          path: {{ .EnvironmentDir }}/tfplan
+
+  apply:
+    name: Apply {{ .Env }} infrastructure
+    if: github.event_name == 'push'
+    needs: plan
+    runs-on: ubuntu-latest
+    environment: {{ .Env }}-iac
+    steps:
+{{ template "setup" . }}
+      - name: Download plan
+        uses: actions/download-artifact@v4
+        with:
+          name: tfplan
+          path: {{ .EnvironmentDir }}
+
+      - name: Terraform apply
+        run: terraform apply -input=false tfplan`
 
 	return applyTemplate(textTemplate, contextTemplate)
 }
@@ -47,3 +47,202 @@ func TestProvider_GithubActions(t *testing.T) {
 		t.Error("expecting", cluster)
 	}
 }
+
+func TestProvider_GithubActionsOIDC(t *testing.T) {
+
+	ctx := mockContext{
+		App:     "my-app",
+		Env:     "dev",
+		Account: "123456789",
+		Region:  "us-west-1",
+	}
+
+	provider, err := GetProviderWithOptions("githubactions", Options{OIDC: true})
+	if err != nil {
+		t.Fail()
+	}
+	artifacts, err := provider.ProvideArtifacts(ctx)
+	if err != nil {
+		t.Fail()
+	}
+	if len(artifacts) != 1 {
+		t.Errorf("expected: %d; actual: %d", 1, len(artifacts))
+	}
+
+	yaml := artifacts[0].FileContents
+	t.Log(yaml)
+
+	if !strings.Contains(yaml, "id-token: write") {
+		t.Error("expecting id-token: write")
+	}
+	role := "role-to-assume: arn:aws:iam::123456789:role/my-app-dev-github-actions"
+	if !strings.Contains(yaml, role) {
+		t.Error("expecting", role)
+	}
+	if strings.Contains(yaml, "secrets.AWS_ACCESS_KEY_ID") {
+		t.Error("not expecting static keys")
+	}
+	if strings.Contains(yaml, "@master") {
+		t.Error("expecting pinned actions")
+	}
+}
+
+func TestProvider_GithubActionsOIDCTerraform(t *testing.T) {
+
+	ctx := mockContext{
+		App:     "my-app",
+		Env:     "dev",
+		Account: "123456789",
+		Region:  "us-west-1",
+	}
+
+	options := Options{
+		OIDC:              true,
+		RoleARN:           "arn:aws:iam::123456789:role/my-role",
+		Terraform:         true,
+		Repository:        "my-org/my-app",
+		InfrastructureDir: "infra",
+	}
+	provider, err := GetProviderWithOptions("githubactions", options)
+	if err != nil {
+		t.Fail()
+	}
+	artifacts, err := provider.ProvideArtifacts(ctx)
+	if err != nil {
+		t.Fail()
+	}
+	if len(artifacts) != 3 {
+		t.Fatalf("expected: %d; actual: %d", 3, len(artifacts))
+	}
+
+	if !strings.Contains(artifacts[0].FileContents, "role-to-assume: "+options.RoleARN) {
+		t.Error("expecting", options.RoleARN)
+	}
+	if artifacts[1].FilePath != "infra/base/github-oidc.tf" {
+		t.Errorf("expected: %s; actual: %s", "infra/base/github-oidc.tf", artifacts[1].FilePath)
+	}
+	if artifacts[2].FilePath != "infra/env/dev/github-oidc.tf" {
+		t.Errorf("expected: %s; actual: %s", "infra/env/dev/github-oidc.tf", artifacts[2].FilePath)
+	}
+	if !strings.Contains(artifacts[2].FileContents, `default     = "my-org/my-app"`) {
+		t.Error("expecting github_repo default")
+	}
+	if strings.Contains(artifacts[2].FileContents, "events:") {
+		t.Error("not expecting events permissions for a service")
+	}
+
+	//scheduled tasks are deployed by updating their rule's target
+	ctx.TemplateType = TemplateTypeScheduledTask
+	artifacts, err = provider.ProvideArtifacts(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(artifacts[2].FileContents)
+	expected := []string{
+		`"events:DescribeRule"`,
+		`"events:ListTargetsByRule"`,
+		`"events:PutTargets"`,
+		`resources = ["arn:aws:events:*:${data.aws_caller_identity.github.account_id}:rule/${var.app}-${var.environment}"]`,
+	}
+	for _, e := range expected {
+		if !strings.Contains(artifacts[2].FileContents, e) {
+			t.Error("expecting", e)
+		}
+	}
+}
+
+func TestProvider_OIDCNotSupported(t *testing.T) {
+	_, err := GetProviderWithOptions("circleciv2", Options{OIDC: true})
+	if err == nil {
+		t.Error("expecting error")
+	}
+}
+
+func TestProvider_GithubActionsPromotion(t *testing.T) {
+	provider, err := GetProvider("githubactions")
+	if err != nil {
+		t.Fail()
+	}
+	artifacts, err := provider.(PromotionProvider).ProvidePromotionArtifacts(mockPromotionContexts())
+	if err != nil {
+		t.Fail()
+	}
+
+	yaml := artifacts[0].FileContents
+	t.Log(yaml)
+	if artifacts[0].FilePath != ".github/workflows/my-app.yml" {
+		t.Errorf("expected: %s; actual: %s", ".github/workflows/my-app.yml", artifacts[0].FilePath)
+	}
+
+	expected := []string{
+		"REPO: 123456789.dkr.ecr.us-east-1.amazonaws.com/my-app",
+		"needs: [build]",
+		"needs: [build, deploy-dev]",
+		"needs: [build, deploy-qa]",
+		"environment: prod",
+		"aws-region: us-west-2",
+		"IMAGE: ${{ needs.build.outputs.image }}",
+		"FARGATE_CLUSTER: my-app-prod",
+	}
+	for _, e := range expected {
+		if !strings.Contains(yaml, e) {
+			t.Error("expecting", e)
+		}
+	}
+}
+
+func TestProvider_GithubActionsIaC(t *testing.T) {
+	options := Options{
+		IaC:      true,
+		Branches: []string{"main"},
+		Backends: map[string]Backend{
+			"dev": {Bucket: "tf-state-my-app", Key: "dev.terraform.tfstate", Region: "us-east-1"},
+		},
+	}
+	provider, err := GetProviderWithOptions("githubactions", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	contexts := mockPromotionContexts()
+	artifacts, err := provider.(InfrastructureProvider).ProvideInfrastructureArtifacts(contexts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(artifacts) != len(contexts) {
+		t.Fatalf("expected: %d; actual: %d", len(contexts), len(artifacts))
+	}
+	if artifacts[0].FilePath != ".github/workflows/dev-iac.yml" {
+		t.Errorf("expected: %s; actual: %s", ".github/workflows/dev-iac.yml", artifacts[0].FilePath)
+	}
+
+	yaml := artifacts[0].FileContents
+	t.Log(yaml)
+	expected := []string{
+		"pull_request:",
+		"- iac/env/dev/**",
+		"- main",
+		"working-directory: iac/env/dev",
+		"terraform init -input=false -backend-config=bucket=tf-state-my-app -backend-config=key=dev.terraform.tfstate -backend-config=region=us-east-1 -backend-config=profile=",
+		"terraform plan -input=false -var aws_profile= -out tfplan",
+		"environment: dev-iac",
+		"terraform apply -input=false tfplan",
+		"secrets.IAC_AWS_ACCESS_KEY_ID",
+	}
+	for _, e := range expected {
+		if !strings.Contains(yaml, e) {
+			t.Error("expecting", e)
+		}
+	}
+
+	//environments without a backend only clear the profile
+	if !strings.Contains(artifacts[2].FileContents, "terraform init -input=false -backend-config=profile=\n") {
+		t.Error("expecting profile backend config")
+	}
+}
+
+func TestProvider_IaCNotSupported(t *testing.T) {
+	_, err := GetProviderWithOptions("jenkins", Options{IaC: true})
+	if err == nil {
+		t.Error("expecting error")
+	}
+}
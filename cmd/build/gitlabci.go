@@ -0,0 +1,332 @@
+package build
+
+import "fmt"
+
+//GitlabCI represents a GitLab CI/CD build provider
+type GitlabCI struct {
+	Options Options
+}
+
+//ProvideArtifacts is the Provider implementation
+func (provider GitlabCI) ProvideArtifacts(context Context) ([]*Artifact, error) {
+	artifacts := []*Artifact{}
+	artifacts = append(artifacts, createYAMLArtifact(".gitlab-ci.yml", getGitlabCIYAML(context, provider.Options), "variables"))
+
+	fmt.Println()
+	fmt.Println(`Be sure to add the following variables to your GitLab project (Settings > CI/CD > Variables):
+  AWS_ACCESS_KEY_ID (terraform state show aws_iam_access_key.cicd_keys)
+  AWS_SECRET_ACCESS_KEY (terraform state show aws_iam_access_key.cicd_keys, masked)`)
+	fmt.Println()
+
+	return artifacts, nil
+}
+
+//ExportTemplates is the TemplateExporter implementation
+func (provider GitlabCI) ExportTemplates() []*Artifact {
+	return []*Artifact{
+		createArtifact(".gitlab-ci.yml", gitlabCITemplate),
+	}
+}
+
+//gitlabCITemplate is the template for .gitlab-ci.yml
+const gitlabCITemplate = `{{- if .IaC }}include:
+  - local: .gitlab/iac-{{ .Env }}.yml
+
+{{ end -}}
+stages:
+{{- if .IaC }}
+  - plan
+{{- end }}
+  - build
+{{- if .Scan }}
+  - scan
+{{- end }}
+  - push
+  - deploy
+{{- if .IaC }}
+  - apply
+{{- end }}
+
+variables:
+  REPO: {{ .Account }}.dkr.ecr.{{ .Region }}.amazonaws.com/{{ .App }}
+  AWS_DEFAULT_REGION: {{ .Region }}
+
+.fargate-create:
+  image: quay.io/turner/fargate-cicd
+  services:
+    - docker:dind
+  variables:
+    DOCKER_HOST: tcp://docker:2375
+    DOCKER_TLS_CERTDIR: ""
+{{- if .NeedsGitTags }}
+    GIT_DEPTH: "0"
+{{- end }}
+  before_script:
+    - export VERSION={{ .Version }}
+    - export IMAGE=${REPO}:${VERSION}-${CI_COMMIT_REF_SLUG}.${CI_PIPELINE_IID}
+
+fargate-create-build:
+  extends: .fargate-create
+  stage: build
+  only:
+{{- range .Branches }}
+    - {{ . }}
+{{- end }}
+  script:
+    - {{ .DockerBuildCommand "${IMAGE}" }}
+    - docker save -o image.tar ${IMAGE}
+  artifacts:
+    paths:
+      - image.tar
+    expire_in: 1 hour
+{{- if .Scan }}
+
+fargate-create-scan:
+  extends: .fargate-create
+  stage: scan
+  only:
+{{- range .Branches }}
+    - {{ . }}
+{{- end }}
+  script:
+    - docker load -i image.tar
+    - {{ .ScanCommand "${IMAGE}" }}
+    - {{ .SBOMCommand "${IMAGE}" }}
+  artifacts:
+    paths:
+      - {{ .SBOMFile }}
+{{- end }}
+
+fargate-create-push:
+  extends: .fargate-create
+  stage: push
+  only:
+{{- range .Branches }}
+    - {{ . }}
+{{- end }}
+  script:
+    - docker load -i image.tar
+    - aws ecr get-login-password | docker login --username AWS --password-stdin {{ .Account }}.dkr.ecr.{{ .Region }}.amazonaws.com
+    - docker push ${IMAGE}
+
+fargate-create-deploy-{{ .Env }}:
+  extends: .fargate-create
+  stage: deploy
+  only:
+{{- range .Branches }}
+    - {{ . }}
+{{- end }}
+  environment:
+    name: {{ .Env }}
+  variables:
+    FARGATE_CLUSTER: {{ .App }}-{{ .Env }}
+    FARGATE_SERVICE: {{ .App }}-{{ .Env }}
+{{- if .IsScheduledTask }}
+    FARGATE_TASK: {{ .App }}-{{ .Env }}
+    FARGATE_RULE: {{ .App }}-{{ .Env }}
+{{- end }}
+  dependencies: []
+  script:
+    - {{ .DeployCommand "${IMAGE}" }}`
+
+func getGitlabCIYAML(context Context, options Options) string {
+	return applyTemplate(gitlabCITemplate, getContextTemplate(context, options))
+}
+
+//ProvidePromotionArtifacts is the PromotionProvider implementation
+func (provider GitlabCI) ProvidePromotionArtifacts(contexts []Context) ([]*Artifact, error) {
+	promotion, err := getPromotionTemplate(contexts, provider.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	artifacts := []*Artifact{}
+	artifacts = append(artifacts, createYAMLArtifact(".gitlab-ci.yml", getGitlabCIPromotionYAML(promotion), "variables"))
+
+	fmt.Println()
+	fmt.Println(`Be sure to add the following variables to your GitLab project (Settings > CI/CD > Variables),
+scoped to each environment:
+  AWS_ACCESS_KEY_ID (terraform state show aws_iam_access_key.cicd_keys)
+  AWS_SECRET_ACCESS_KEY (terraform state show aws_iam_access_key.cicd_keys, masked)`)
+	fmt.Println("Deployments after " + promotion.Env + " are manual. To restrict who can run them, protect those environments.")
+	fmt.Printf("Images are pushed to the %s registry so other environments need permission to pull from it.\n", promotion.Env)
+	fmt.Println()
+
+	return artifacts, nil
+}
+
+func getGitlabCIPromotionYAML(promotion promotionTemplate) string {
+	textTemplate := `{{- if .IaC }}include:
+{{- range .Stages }}
+  - local: .gitlab/iac-{{ .Env }}.yml
+{{- end }}
+
+{{ end -}}
+stages:
+{{- if .IaC }}
+  - plan
+{{- end }}
+  - build
+{{- range .Stages }}
+  - deploy_{{ .Env }}
+{{- end }}
+{{- if .IaC }}
+  - apply
+{{- end }}
+
+workflow:
+  rules:
+{{- range .Branches }}
+    - if: '$CI_COMMIT_BRANCH == "{{ . }}"'
+{{- end }}
+    - if: '$CI_COMMIT_TAG =~ /^v/'
+{{- if .IaC }}
+    - if: '$CI_PIPELINE_SOURCE == "merge_request_event"'
+{{- end }}
+
+variables:
+  REPO: {{ .Account }}.dkr.ecr.{{ .Region }}.amazonaws.com/{{ .App }}
+
+.fargate-create:
+  image: quay.io/turner/fargate-cicd
+  services:
+    - docker:dind
+  variables:
+    DOCKER_HOST: tcp://docker:2375
+    DOCKER_TLS_CERTDIR: ""
+{{- if .NeedsGitTags }}
+    GIT_DEPTH: "0"
+{{- end }}
+
+fargate-create-build:
+  extends: .fargate-create
+  stage: build
+{{- if .IaC }}
+  except:
+    - merge_requests
+{{- end }}
+  environment:
+    name: {{ .Env }}
+    action: prepare
+  variables:
+    AWS_DEFAULT_REGION: {{ .Region }}
+  script:
+    - export VERSION={{ .Version }}
+    - export IMAGE=${REPO}:${VERSION}-${CI_COMMIT_REF_SLUG}.${CI_PIPELINE_IID}
+    - {{ .DockerBuildCommand "${IMAGE}" }}
+{{- if .Scan }}
+    - {{ .ScanCommand "${IMAGE}" }}
+    - {{ .SBOMCommand "${IMAGE}" }}
+{{- end }}
+    - aws ecr get-login-password | docker login --username AWS --password-stdin {{ .Account }}.dkr.ecr.{{ .Region }}.amazonaws.com
+    - docker push ${IMAGE}
+    - DIGEST=$(docker inspect --format '{{"{{index .RepoDigests 0}}"}}' ${IMAGE})
+    - if [ -z "${DIGEST}" ]; then echo "unable to get the digest of ${IMAGE}"; exit 1; fi
+    - echo "IMAGE=${DIGEST}" > build.env
+  artifacts:
+{{- if .Scan }}
+    paths:
+      - {{ .SBOMFile }}
+{{- end }}
+    reports:
+      dotenv: build.env
+{{ range .Stages }}
+fargate-create-deploy-{{ .Env }}:
+  extends: .fargate-create
+  stage: deploy_{{ .Env }}
+{{- if .IaC }}
+  except:
+    - merge_requests
+{{- end }}
+{{- if .Previous }}
+  when: manual
+  allow_failure: false
+{{- end }}
+  environment:
+    name: {{ .Env }}
+  variables:
+    AWS_DEFAULT_REGION: {{ .Region }}
+    FARGATE_CLUSTER: {{ .App }}-{{ .Env }}
+    FARGATE_SERVICE: {{ .App }}-{{ .Env }}
+{{- if .IsScheduledTask }}
+    FARGATE_TASK: {{ .App }}-{{ .Env }}
+    FARGATE_RULE: {{ .App }}-{{ .Env }}
+{{- end }}
+  script:
+    - {{ .DeployCommand "${IMAGE}" }}
+{{ end }}`
+
+	return applyTemplate(textTemplate, promotion)
+}
+
+//ProvideInfrastructureArtifacts is the InfrastructureProvider implementation
+//(the jobs are included by .gitlab-ci.yml)
+func (provider GitlabCI) ProvideInfrastructureArtifacts(contexts []Context) ([]*Artifact, error) {
+	artifacts := []*Artifact{}
+	for _, context := range contexts {
+		yaml := getGitlabCIIaCYAML(context, provider.Options)
+		artifacts = append(artifacts, createYAMLArtifact(fmt.Sprintf(".gitlab/iac-%s.yml", context.GetEnvironment()), yaml))
+	}
+
+	fmt.Println()
+	fmt.Println(`Be sure to add the following variables to your GitLab project (Settings > CI/CD > Variables),
+credentials that can manage the environment's resources and terraform state:
+  IAC_AWS_ACCESS_KEY_ID
+  IAC_AWS_SECRET_ACCESS_KEY (masked)`)
+	fmt.Println("Terraform changes are applied manually. To restrict who can apply them, protect the *-iac environments.")
+	fmt.Println()
+
+	return artifacts, nil
+}
+
+func getGitlabCIIaCYAML(context Context, options Options) string {
+	textTemplate := `.iac_{{ .Env }}:
+  image:
+    name: hashicorp/terraform:latest
+    entrypoint: [""]
+  variables:
+    AWS_ACCESS_KEY_ID: ${IAC_AWS_ACCESS_KEY_ID}
+    AWS_SECRET_ACCESS_KEY: ${IAC_AWS_SECRET_ACCESS_KEY}
+    AWS_DEFAULT_REGION: {{ .Region }}
+  before_script:
+    - cd {{ .EnvironmentDir }}
+    - terraform init -input=false {{ .BackendConfig }}
+
+plan_{{ .Env }}_iac:
+  extends: .iac_{{ .Env }}
+  stage: plan
+  rules:
+    - if: '$CI_PIPELINE_SOURCE == "merge_request_event"'
+      changes:
+        - {{ .EnvironmentDir }}/**/*
+{{- range .Branches }}
+    - if: '$CI_COMMIT_BRANCH == "{{ . }}"'
+      changes:
+        - {{ $.EnvironmentDir }}/**/*
+{{- end }}
+  script:
+    - terraform plan -input=false -var aws_profile= -out tfplan
+  artifacts:
+    paths:
+      - {{ .EnvironmentDir }}/tfplan
+    expire_in: 1 week
+
+apply_{{ .Env }}_iac:
+  extends: .iac_{{ .Env }}
+  stage: apply
+  environment:
+    name: {{ .Env }}-iac
+  needs:
+    - plan_{{ .Env }}_iac
+  rules:
+{{- range .Branches }}
+    - if: '$CI_COMMIT_BRANCH == "{{ . }}"'
+      changes:
+        - {{ $.EnvironmentDir }}/**/*
+      when: manual
+{{- end }}
+  script:
+    - terraform apply -input=false tfplan`
+
+	return applyTemplate(textTemplate, getContextTemplate(context, options))
+}
@@ -0,0 +1,253 @@
+package build
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestProvider_GitlabCI(t *testing.T) {
+
+	ctx := mockContext{
+		App:     "my-app",
+		Env:     "dev",
+		Account: "123456789",
+		Region:  "us-west-1",
+	}
+
+	provider, err := GetProvider("gitlabci")
+	if err != nil {
+		t.Fail()
+	}
+	artifacts, err := provider.ProvideArtifacts(ctx)
+	if err != nil {
+		t.Fail()
+	}
+	if artifacts == nil {
+		t.Fail()
+	}
+
+	yaml := artifacts[0].FileContents
+	t.Log(yaml)
+	if artifacts[0].FilePath != ".gitlab-ci.yml" {
+		t.Fail()
+	}
+
+	repo := fmt.Sprintf(`REPO: %v.dkr.ecr.%s.amazonaws.com/%v`, ctx.Account, ctx.Region, ctx.App)
+	if !strings.Contains(yaml, repo) {
+		t.Error("expecting", repo)
+	}
+	job := fmt.Sprintf("fargate-create-deploy-%s:", ctx.Env)
+	if !strings.Contains(yaml, job) {
+		t.Error("expecting", job)
+	}
+	cluster := fmt.Sprintf("FARGATE_CLUSTER: %s-%s", ctx.App, ctx.Env)
+	if !strings.Contains(yaml, cluster) {
+		t.Error("expecting", cluster)
+	}
+}
+
+func TestProvider_GitlabCIMerge(t *testing.T) {
+	provider, _ := GetProvider("gitlabci")
+	artifacts, err := provider.ProvideArtifacts(mockContext{App: "my-app", Env: "dev", Account: "123456789", Region: "us-west-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	existing := `default:
+  image: node:10
+stages:
+  - test
+test:
+  stage: test
+  script:
+    - npm test
+
+build:
+  stage: build
+  script:
+    - npm run build
+`
+	merged, err := MergeYAML(existing, artifacts[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(merged)
+
+	//the user's jobs keep running in their image
+	if !strings.Contains(merged, "default:\n  image: node:10\n") {
+		t.Error("expecting default image to be kept")
+	}
+	if !strings.Contains(merged, "\nfargate-create-build:\n  extends: .fargate-create\n") {
+		t.Error("expecting build job to extend .fargate-create")
+	}
+
+	//the generated jobs don't collide with the user's
+	if !strings.Contains(merged, "\nbuild:\n  stage: build\n  script:\n    - npm run build\n") {
+		t.Error("expecting the user's build job to be kept")
+	}
+	expectedMarker := mergeMarker + " variables.REPO, variables.AWS_DEFAULT_REGION, .fargate-create, fargate-create-build, fargate-create-push, fargate-create-deploy-dev"
+	if !strings.HasPrefix(merged, expectedMarker+"\n") {
+		t.Errorf("expected: %s; actual: %s", expectedMarker, strings.Split(merged, "\n")[0])
+	}
+	err = ValidateArtifact(&Artifact{FilePath: artifacts[0].FilePath, FileContents: merged})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestProvider_GitlabCIPromotion(t *testing.T) {
+	provider, err := GetProvider("gitlabci")
+	if err != nil {
+		t.Fail()
+	}
+	artifacts, err := provider.(PromotionProvider).ProvidePromotionArtifacts(mockPromotionContexts())
+	if err != nil {
+		t.Fail()
+	}
+
+	yaml := artifacts[0].FileContents
+	t.Log(yaml)
+
+	expected := []string{
+		"dotenv: build.env",
+		"fargate-create-deploy-dev:",
+		"fargate-create-deploy-qa:",
+		"fargate-create-deploy-prod:",
+		"FARGATE_CLUSTER: my-app-prod",
+	}
+	for _, e := range expected {
+		if !strings.Contains(yaml, e) {
+			t.Error("expecting", e)
+		}
+	}
+
+	//only promotions are manual
+	if strings.Count(yaml, "when: manual") != 2 {
+		t.Errorf("expected: %d; actual: %d", 2, strings.Count(yaml, "when: manual"))
+	}
+}
+
+func TestProvider_GitlabCIScheduledTask(t *testing.T) {
+
+	ctx := mockContext{
+		App:          "my-app",
+		Env:          "dev",
+		Account:      "123456789",
+		Region:       "us-west-1",
+		TemplateType: TemplateTypeScheduledTask,
+	}
+
+	provider, err := GetProvider("gitlabci")
+	if err != nil {
+		t.Fail()
+	}
+	artifacts, err := provider.ProvideArtifacts(ctx)
+	if err != nil {
+		t.Fail()
+	}
+
+	yaml := artifacts[0].FileContents
+	t.Log(yaml)
+
+	deploy := "fargate events target -r $(fargate task register -i ${IMAGE})"
+	if !strings.Contains(yaml, deploy) {
+		t.Error("expecting", deploy)
+	}
+	if strings.Contains(yaml, "fargate service deploy") {
+		t.Error("not expecting fargate service deploy")
+	}
+	rule := "FARGATE_RULE: my-app-dev"
+	if !strings.Contains(yaml, rule) {
+		t.Error("expecting", rule)
+	}
+}
+
+func TestValidateContext(t *testing.T) {
+	for _, templateType := range []string{"", TemplateTypeService, TemplateTypeScheduledTask} {
+		if err := ValidateContext(mockContext{TemplateType: templateType}); err != nil {
+			t.Errorf("expected: %s; actual: %s", "nil", err)
+		}
+	}
+	if err := ValidateContext(mockContext{TemplateType: "Airflow"}); err == nil {
+		t.Error("expecting error")
+	}
+}
+
+func TestProvider_GitlabCIIaC(t *testing.T) {
+	ctx := mockContext{
+		App:     "my-app",
+		Env:     "dev",
+		Account: "123456789",
+		Region:  "us-east-1",
+	}
+	provider, err := GetProviderWithOptions("gitlabci", Options{IaC: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//the pipeline includes the environment's terraform jobs
+	artifacts, err := provider.ProvideArtifacts(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	yaml := artifacts[0].FileContents
+	t.Log(yaml)
+	expected := []string{
+		"- local: .gitlab/iac-dev.yml",
+		"  - plan\n",
+		"  - apply\n",
+	}
+	for _, e := range expected {
+		if !strings.Contains(yaml, e) {
+			t.Error("expecting", e)
+		}
+	}
+
+	artifacts, err = provider.(InfrastructureProvider).ProvideInfrastructureArtifacts([]Context{ctx})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if artifacts[0].FilePath != ".gitlab/iac-dev.yml" {
+		t.Errorf("expected: %s; actual: %s", ".gitlab/iac-dev.yml", artifacts[0].FilePath)
+	}
+	yaml = artifacts[0].FileContents
+	t.Log(yaml)
+	expected = []string{
+		"plan_dev_iac:",
+		"apply_dev_iac:",
+		"- cd iac/env/dev",
+		"- iac/env/dev/**/*",
+		"$CI_PIPELINE_SOURCE == \"merge_request_event\"",
+		"when: manual",
+	}
+	for _, e := range expected {
+		if !strings.Contains(yaml, e) {
+			t.Error("expecting", e)
+		}
+	}
+}
+
+func TestProvider_GitlabCIPromotionIaC(t *testing.T) {
+	provider, err := GetProviderWithOptions("gitlabci", Options{IaC: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	artifacts, err := provider.(PromotionProvider).ProvidePromotionArtifacts(mockPromotionContexts())
+	if err != nil {
+		t.Fatal(err)
+	}
+	yaml := artifacts[0].FileContents
+	t.Log(yaml)
+
+	for _, env := range []string{"dev", "qa", "prod"} {
+		include := "- local: .gitlab/iac-" + env + ".yml"
+		if !strings.Contains(yaml, include) {
+			t.Error("expecting", include)
+		}
+	}
+
+	//build and deploy jobs don't run in merge request pipelines
+	if strings.Count(yaml, "- merge_requests") != 4 {
+		t.Errorf("expected: %d; actual: %d", 4, strings.Count(yaml, "- merge_requests"))
+	}
+}
@@ -0,0 +1,127 @@
+package build
+
+import "fmt"
+
+//Jenkins represents a Jenkins (declarative pipeline) build provider
+type Jenkins struct {
+	Options Options
+}
+
+//ProvideArtifacts is the Provider implementation
+func (provider Jenkins) ProvideArtifacts(context Context) ([]*Artifact, error) {
+	artifacts := []*Artifact{}
+	artifacts = append(artifacts, createArtifact("Jenkinsfile", getJenkinsfile(context, provider.Options)))
+
+	fmt.Println()
+	fmt.Printf(`Be sure to add the following "Secret text" credentials to Jenkins:
+  %s-aws-access-key-id (terraform state show aws_iam_access_key.cicd_keys)
+  %s-aws-secret-access-key (terraform state show aws_iam_access_key.cicd_keys)
+`, context.GetApp(), context.GetApp())
+	fmt.Println()
+
+	return artifacts, nil
+}
+
+//ExportTemplates is the TemplateExporter implementation
+func (provider Jenkins) ExportTemplates() []*Artifact {
+	return []*Artifact{
+		createArtifact("Jenkinsfile", jenkinsfileTemplate),
+	}
+}
+
+//jenkinsfileTemplate is the template for Jenkinsfile
+const jenkinsfileTemplate = `pipeline {
+  // requires the Docker Pipeline plugin and agents that can run docker
+  agent {
+    docker {
+      image 'quay.io/turner/fargate-cicd'
+      args '-v /var/run/docker.sock:/var/run/docker.sock'
+    }
+  }
+
+  environment {
+    AWS_DEFAULT_REGION = '{{ .Region }}'
+    AWS_ACCESS_KEY_ID = credentials('{{ .App }}-aws-access-key-id')
+    AWS_SECRET_ACCESS_KEY = credentials('{{ .App }}-aws-secret-access-key')
+    REGISTRY = '{{ .Account }}.dkr.ecr.{{ .Region }}.amazonaws.com'
+    REPO = "${REGISTRY}/{{ .App }}"
+{{- if .VersionScript }}
+    VERSION = sh(script: '{{ .VersionScript }}', returnStdout: true).trim()
+{{- else }}
+    VERSION = '{{ .Version }}'
+{{- end }}
+    IMAGE = "${REPO}:${VERSION}-${BUILD_NUMBER}"
+  }
+
+  // images are only built and pushed for the deployed branches
+  stages {
+    stage('Build') {
+      when {
+        anyOf {
+{{- range .Branches }}
+          branch '{{ . }}'
+{{- end }}
+        }
+      }
+      steps {
+        sh '{{ .DockerBuildCommand "${IMAGE}" }}'
+      }
+    }
+{{- if .Scan }}
+
+    stage('Scan') {
+      when {
+        anyOf {
+{{- range .Branches }}
+          branch '{{ . }}'
+{{- end }}
+        }
+      }
+      steps {
+        sh '{{ .ScanCommand "${IMAGE}" }}'
+        sh '{{ .SBOMCommand "${IMAGE}" }}'
+        archiveArtifacts artifacts: '{{ .SBOMFile }}'
+      }
+    }
+{{- end }}
+
+    stage('Push') {
+      when {
+        anyOf {
+{{- range .Branches }}
+          branch '{{ . }}'
+{{- end }}
+        }
+      }
+      steps {
+        sh 'aws ecr get-login-password | docker login --username AWS --password-stdin ${REGISTRY}'
+        sh 'docker push ${IMAGE}'
+      }
+    }
+
+    stage('Deploy to {{ .Env }}') {
+      when {
+        anyOf {
+{{- range .Branches }}
+          branch '{{ . }}'
+{{- end }}
+        }
+      }
+      environment {
+        FARGATE_CLUSTER = '{{ .App }}-{{ .Env }}'
+        FARGATE_SERVICE = '{{ .App }}-{{ .Env }}'
+{{- if .IsScheduledTask }}
+        FARGATE_TASK = '{{ .App }}-{{ .Env }}'
+        FARGATE_RULE = '{{ .App }}-{{ .Env }}'
+{{- end }}
+      }
+      steps {
+        sh '{{ .DeployCommand "${IMAGE}" }}'
+      }
+    }
+  }
+}`
+
+func getJenkinsfile(context Context, options Options) string {
+	return applyTemplate(jenkinsfileTemplate, getContextTemplate(context, options))
+}
@@ -0,0 +1,78 @@
+package build
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestProvider_Jenkins(t *testing.T) {
+
+	ctx := mockContext{
+		App:     "my-app",
+		Env:     "dev",
+		Account: "123456789",
+		Region:  "us-west-1",
+	}
+
+	provider, err := GetProvider("jenkins")
+	if err != nil {
+		t.Fail()
+	}
+	artifacts, err := provider.ProvideArtifacts(ctx)
+	if err != nil {
+		t.Fail()
+	}
+	if artifacts == nil {
+		t.Fail()
+	}
+
+	jenkinsfile := artifacts[0].FileContents
+	t.Log(jenkinsfile)
+	if artifacts[0].FilePath != "Jenkinsfile" {
+		t.Fail()
+	}
+
+	registry := fmt.Sprintf(`REGISTRY = '%v.dkr.ecr.%s.amazonaws.com'`, ctx.Account, ctx.Region)
+	if !strings.Contains(jenkinsfile, registry) {
+		t.Error("expecting", registry)
+	}
+	accessKey := fmt.Sprintf("AWS_ACCESS_KEY_ID = credentials('%s-aws-access-key-id')", ctx.App)
+	if !strings.Contains(jenkinsfile, accessKey) {
+		t.Error("expecting", accessKey)
+	}
+	stage := fmt.Sprintf("stage('Deploy to %s')", ctx.Env)
+	if !strings.Contains(jenkinsfile, stage) {
+		t.Error("expecting", stage)
+	}
+	cluster := fmt.Sprintf("FARGATE_CLUSTER = '%s-%s'", ctx.App, ctx.Env)
+	if !strings.Contains(jenkinsfile, cluster) {
+		t.Error("expecting", cluster)
+	}
+}
+
+func TestProvider_JenkinsBranches(t *testing.T) {
+	ctx := mockContext{
+		App:     "my-app",
+		Env:     "dev",
+		Account: "123456789",
+		Region:  "us-west-1",
+	}
+	jenkinsfile := getJenkinsfile(ctx, Options{Branches: []string{"develop", "release"}, Scan: true})
+	t.Log(jenkinsfile)
+
+	//every stage (not just the deploy) only runs for the configured branches
+	for _, stage := range []string{"Build", "Scan", "Push", "Deploy to dev"} {
+		parts := strings.SplitN(jenkinsfile, fmt.Sprintf("stage('%s') {", stage), 2)
+		if len(parts) != 2 {
+			t.Error("expecting", stage)
+			continue
+		}
+		when := strings.Split(parts[1], "steps {")[0]
+		for _, branch := range []string{"branch 'develop'", "branch 'release'"} {
+			if !strings.Contains(when, branch) {
+				t.Errorf("expecting %s to be gated on %s", stage, branch)
+			}
+		}
+	}
+}
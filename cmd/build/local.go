@@ -1,32 +1,81 @@
 package build
 
 //Local represents a local build provider
-type Local struct{}
+type Local struct {
+	Options Options
+}
 
 //ProvideArtifacts is the Provider implementation
 func (provider Local) ProvideArtifacts(context Context) ([]*Artifact, error) {
 
-	//output a build.sh that uses docker to build/push
-	contextTemplate := getContextTemplate(context)
+	//output a build.sh that uses docker to build/push (and optionally deploy)
+	contextTemplate := getContextTemplate(context, provider.Options)
 	artifacts := []*Artifact{}
-	buildScript := createArtifact("build.sh", getLocalBuildScript(contextTemplate))
+	script, err := getLocalBuildScript(contextTemplate)
+	if err != nil {
+		return nil, err
+	}
+	buildScript := createArtifact("build.sh", script)
 	buildScript.FileMode = 0700
 	artifacts = append(artifacts, buildScript)
 	return artifacts, nil
 }
 
-func getLocalBuildScript(context contextTemplate) string {
-	textTemplate := `
-#! /bin/bash
+//ExportTemplates is the TemplateExporter implementation
+func (provider Local) ExportTemplates() []*Artifact {
+	return []*Artifact{
+		{FilePath: "build.sh", FileContents: localBuildScriptTemplate, FileMode: 0700},
+	}
+}
+
+//localBuildScriptTemplate is the template for build.sh
+const localBuildScriptTemplate = `#!/bin/bash
 set -e
+{{ if .Profile }}
+export AWS_PROFILE={{ .Profile }}
+{{- end }}
+export AWS_DEFAULT_REGION={{ .Region }}
+
+VERSION={{ .Version }}
+IMAGE="{{ .Account }}.dkr.ecr.{{ .Region }}.amazonaws.com/{{ .App }}:${VERSION}"
 
+# login to ECR
+version=$(aws --version 2>&1 | awk -F'[/.]' '{print $2}')
+if [ $version -eq "1" ]; then
+  login=$(aws ecr get-login --no-include-email) && eval "$login"
+else
+  aws ecr get-login-password | docker login --username AWS --password-stdin {{ .Account }}.dkr.ecr.{{ .Region }}.amazonaws.com
+fi
+{{ if .Platforms }}
+# build and push multi-arch image
+{{ .DockerBuildCommand "${IMAGE}" }}
+{{- else }}
 # build image
-IMAGE="{{ .Account }}.dkr.ecr.us-east-1.amazonaws.com/{{ .App }}:0.1.0"
-docker build -t ${IMAGE} .
+{{ .DockerBuildCommand "${IMAGE}" }}
+{{- if .Scan }}
+
+# scan image for vulnerabilities and generate SBOM
+{{ .ScanCommand "${IMAGE}" }}
+{{ .SBOMCommand "${IMAGE}" }}
+{{- end }}
 
 # push image to ECR repo
-login=$(aws ecr get-login --no-include-email) && eval "$login"
 docker push ${IMAGE}
+{{- end }}
+{{- if .Deploy }}
+
+# deploy image
+export FARGATE_CLUSTER={{ .App }}-{{ .Env }}
+{{- if .IsScheduledTask }}
+export FARGATE_TASK={{ .App }}-{{ .Env }}
+export FARGATE_RULE={{ .App }}-{{ .Env }}
+{{- else }}
+export FARGATE_SERVICE={{ .App }}-{{ .Env }}
+{{- end }}
+{{ .DeployCommand "${IMAGE}" }}
+{{- end }}
 `
-	return applyTemplate(textTemplate, context)
+
+func getLocalBuildScript(context contextTemplate) (string, error) {
+	return renderTemplate(localBuildScriptTemplate, context)
 }
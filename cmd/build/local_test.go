@@ -7,10 +7,12 @@ import (
 )
 
 type mockContext struct {
-	App     string
-	Env     string
-	Account string
-	Region  string
+	App          string
+	Env          string
+	Account      string
+	Region       string
+	TemplateType string
+	Profile      string
 }
 
 func (c mockContext) GetApp() string {
@@ -29,6 +31,22 @@ func (c mockContext) GetRegion() string {
 	return c.Region
 }
 
+func (c mockContext) GetTemplateType() string {
+	return c.TemplateType
+}
+
+func (c mockContext) GetProfile() string {
+	return c.Profile
+}
+
+func mockPromotionContexts() []Context {
+	return []Context{
+		mockContext{App: "my-app", Env: "dev", Account: "123456789", Region: "us-east-1"},
+		mockContext{App: "my-app", Env: "qa", Account: "123456789", Region: "us-east-1"},
+		mockContext{App: "my-app", Env: "prod", Account: "987654321", Region: "us-west-2"},
+	}
+}
+
 func TestProvider_Local(t *testing.T) {
 
 	ctx := mockContext{
@@ -54,8 +72,102 @@ func TestProvider_Local(t *testing.T) {
 	}
 	t.Log(artifacts[0].FileContents)
 
-	image := fmt.Sprintf(`IMAGE="%v.dkr.ecr.us-east-1.amazonaws.com/%v:0.1.0"`, ctx.Account, ctx.App)
-	if !strings.Contains(artifacts[0].FileContents, image) {
+	script := artifacts[0].FileContents
+	if !strings.HasPrefix(script, "#!/bin/bash\n") {
+		t.Error("expecting shebang on the first line")
+	}
+	image := fmt.Sprintf(`IMAGE="%v.dkr.ecr.us-east-1.amazonaws.com/%v:${VERSION}"`, ctx.Account, ctx.App)
+	if !strings.Contains(script, image) {
 		t.Errorf("expecting %s", image)
 	}
+	if strings.Contains(script, "fargate service deploy") {
+		t.Error("not expecting deploy")
+	}
+}
+
+func TestProvider_LocalRegionProfile(t *testing.T) {
+	ctx := mockContext{
+		App:     "my-app",
+		Env:     "dev",
+		Account: "123456789",
+		Region:  "us-west-2",
+		Profile: "my-profile",
+	}
+
+	provider, err := GetProvider("local")
+	if err != nil {
+		t.Fail()
+	}
+	artifacts, err := provider.ProvideArtifacts(ctx)
+	if err != nil {
+		t.Fail()
+	}
+	script := artifacts[0].FileContents
+	t.Log(script)
+
+	expected := []string{
+		"export AWS_PROFILE=my-profile",
+		"export AWS_DEFAULT_REGION=us-west-2",
+		"123456789.dkr.ecr.us-west-2.amazonaws.com/my-app",
+		"aws ecr get-login-password",
+		"docker build -t ${IMAGE} .",
+		"docker push ${IMAGE}",
+	}
+	for _, e := range expected {
+		if !strings.Contains(script, e) {
+			t.Error("expecting", e)
+		}
+	}
+	if strings.Contains(script, "us-east-1") {
+		t.Error("not expecting us-east-1")
+	}
+}
+
+func TestProvider_LocalDeployMultiArch(t *testing.T) {
+	ctx := mockContext{
+		App:          "my-app",
+		Env:          "dev",
+		Account:      "123456789",
+		Region:       "us-east-1",
+		TemplateType: TemplateTypeScheduledTask,
+	}
+
+	provider, err := GetProviderWithOptions("local", Options{
+		Deploy:    true,
+		Platforms: []string{"linux/amd64", "linux/arm64"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	artifacts, err := provider.ProvideArtifacts(ctx)
+	if err != nil {
+		t.Fail()
+	}
+	script := artifacts[0].FileContents
+	t.Log(script)
+
+	expected := []string{
+		"docker buildx build --platform linux/amd64,linux/arm64 --push -t ${IMAGE} .",
+		"export FARGATE_TASK=my-app-dev",
+		"fargate events target -r $(fargate task register -i ${IMAGE})",
+	}
+	for _, e := range expected {
+		if !strings.Contains(script, e) {
+			t.Error("expecting", e)
+		}
+	}
+	if strings.Contains(script, "docker push") {
+		t.Error("not expecting docker push")
+	}
+}
+
+func TestProvider_LocalOnlyOptions(t *testing.T) {
+	_, err := GetProviderWithOptions("gitlabci", Options{Deploy: true})
+	if err == nil {
+		t.Error("expecting error")
+	}
+	_, err = GetProviderWithOptions("circleciv2", Options{Platforms: []string{"linux/arm64"}})
+	if err == nil {
+		t.Error("expecting error")
+	}
 }
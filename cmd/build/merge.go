@@ -0,0 +1,380 @@
+package build
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+//mergeMarker prefixes the comment that identifies the entries owned by fargate-create
+const mergeMarker = "# fargate-create:"
+
+//MergeYAML merges a generated YAML artifact into the contents of an existing file.
+//The children of the artifact's merge keys (e.g., jobs) are added or replaced, lists
+//(e.g., stages) are combined, and other keys are only added if they're missing
+//(values that were set by the user are left alone). The entries owned by fargate-create
+//are recorded in a comment so that they can be updated, or removed if they're no longer
+//generated. Comments, anchors, key order, and the blank lines between entries in the
+//existing file are preserved (other formatting, e.g., indentation, is normalized).
+func MergeYAML(existing string, artifact *Artifact) (string, error) {
+	if !artifact.Merge {
+		return "", errors.New("artifact can't be merged: " + artifact.FilePath)
+	}
+	current, err := parseYAMLDocument(removeMergeMarker(existing))
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", artifact.FilePath, err)
+	}
+	generated, err := parseYAMLDocument(artifact.FileContents)
+	if err != nil {
+		return "", fmt.Errorf("%s (generated): %v", artifact.FilePath, err)
+	}
+
+	if strings.TrimSpace(removeMergeMarker(existing)) == "" {
+		//new file, all of its entries are owned
+		return getMergeMarker(getMergeEntries(generated.Content[0], artifact.MergeKeys)) + "\n" + artifact.FileContents, nil
+	}
+	before, err := encodeYAML(current)
+	if err != nil {
+		return "", err
+	}
+
+	m := merger{
+		mergeKeys: artifact.MergeKeys,
+		previous:  parseMergeMarker(existing, artifact.MergeKeys),
+	}
+	m.mergeMapping(current.Content[0], generated.Content[0], []string{})
+
+	//remove entries that used to be generated but aren't anymore
+	entries := getMergeEntries(generated.Content[0], artifact.MergeKeys)
+	for _, entry := range m.previous {
+		if !containsPath(entries, entry) {
+			deletePath(current.Content[0], entry)
+		}
+	}
+
+	after, err := encodeYAML(current)
+	if err != nil {
+		return "", err
+	}
+	marker := getMergeMarker(m.owned)
+	if after == before && marker == getMergeMarker(m.previous) {
+		//unchanged, keep the existing formatting
+		return existing, nil
+	}
+
+	//the encoder doesn't keep blank lines so they're added back between entries
+	spaced := getSpacedKeys(existing)
+	for key := range getSpacedKeys(artifact.FileContents) {
+		spaced[key] = true
+	}
+	return marker + "\n" + addBlankLines(after, spaced), nil
+}
+
+//matches the keys of top level entries and their children (e.g., jobs)
+var spacedKeyRegex = regexp.MustCompile(`^(  )?[^\s#-][^:]*:`)
+
+//returns the keys (with their indentation) that are preceded by a blank line, ignoring comments
+func getSpacedKeys(contents string) map[string]bool {
+	result := map[string]bool{}
+	lines := strings.Split(contents, "\n")
+	for i, line := range lines {
+		key := spacedKeyRegex.FindString(line)
+		if key == "" {
+			continue
+		}
+		j := i - 1
+		for j >= 0 && strings.HasPrefix(strings.TrimSpace(lines[j]), "#") {
+			j--
+		}
+		if j >= 0 && strings.TrimSpace(lines[j]) == "" {
+			result[key] = true
+		}
+	}
+	return result
+}
+
+//adds a blank line before the (comments of the) entries with spaced keys
+func addBlankLines(contents string, spaced map[string]bool) string {
+	result := []string{}
+	for _, line := range strings.Split(contents, "\n") {
+		if key := spacedKeyRegex.FindString(line); key != "" && spaced[key] {
+			i := len(result)
+			for i > 0 && strings.HasPrefix(strings.TrimSpace(result[i-1]), "#") {
+				i--
+			}
+			if i > 0 && result[i-1] != "" {
+				result = append(result[:i], append([]string{""}, result[i:]...)...)
+			}
+		}
+		result = append(result, line)
+	}
+	return strings.Join(result, "\n")
+}
+
+func encodeYAML(doc *yaml.Node) (string, error) {
+	untagMergeKeys(doc)
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	err := encoder.Encode(doc)
+	return buf.String(), err
+}
+
+//merger tracks the entries owned by fargate-create while merging
+type merger struct {
+	mergeKeys []string
+	previous  [][]string
+	owned     [][]string
+}
+
+func (m *merger) mergeMapping(current *yaml.Node, generated *yaml.Node, parent []string) {
+	for i := 0; i+1 < len(generated.Content); i += 2 {
+		key, value := generated.Content[i], generated.Content[i+1]
+		path := append(append([]string{}, parent...), key.Value)
+		existing := getValue(current, key.Value)
+
+		switch {
+		case containsString(m.mergeKeys, strings.Join(path, ".")) && value.Kind == yaml.MappingNode:
+			if existing == nil || existing.Kind != yaml.MappingNode {
+				existing = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+				setValue(current, key, existing)
+			}
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				m.mergeEntry(existing, value.Content[j], value.Content[j+1], append(append([]string{}, path...), value.Content[j].Value), true)
+			}
+		case m.isMergeKeyParent(path) && value.Kind == yaml.MappingNode && (existing == nil || existing.Kind == yaml.MappingNode):
+			if existing == nil {
+				existing = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+				setValue(current, key, existing)
+			}
+			m.mergeMapping(existing, value, path)
+		default:
+			m.mergeEntry(current, key, value, path, false)
+		}
+	}
+}
+
+//merges an entry. missing entries are added, lists are combined, and entries that
+//are owned by fargate-create (or are jobs, i.e., the non-scalar children of merge keys) are replaced
+func (m *merger) mergeEntry(current *yaml.Node, key *yaml.Node, value *yaml.Node, path []string, isChild bool) {
+	existing := getValue(current, key.Value)
+	owned := containsPath(m.previous, path)
+	switch {
+	case existing == nil:
+		setValue(current, key, value)
+		owned = true
+	case existing.Kind == yaml.SequenceNode && value.Kind == yaml.SequenceNode:
+		unionSequences(existing, value)
+	case owned || (isChild && value.Kind != yaml.ScalarNode):
+		setValue(current, key, value)
+		owned = true
+	}
+	if owned {
+		m.owned = append(m.owned, path)
+	}
+}
+
+//returns true if a path is a parent of a merge key (e.g., pipelines for pipelines.branches)
+func (m *merger) isMergeKeyParent(path []string) bool {
+	for _, mergeKey := range m.mergeKeys {
+		if strings.HasPrefix(mergeKey, strings.Join(path, ".")+".") {
+			return true
+		}
+	}
+	return false
+}
+
+//parses a YAML document (an empty document is an empty mapping)
+func parseYAMLDocument(contents string) (*yaml.Node, error) {
+	doc := yaml.Node{}
+	err := yaml.Unmarshal([]byte(contents), &doc)
+	if err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		doc.Kind = yaml.DocumentNode
+		doc.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}
+	}
+	if doc.Content[0].Kind != yaml.MappingNode {
+		return nil, errors.New("expecting a mapping at the top level")
+	}
+	return &doc, nil
+}
+
+//returns the paths of the entries in a generated document, which are its top level keys
+//except for merge keys (e.g., jobs or pipelines.branches), whose children are the entries
+func getMergeEntries(doc *yaml.Node, mergeKeys []string) [][]string {
+	return getMergeEntriesIn(doc, []string{}, mergeKeys)
+}
+
+func getMergeEntriesIn(doc *yaml.Node, parent []string, mergeKeys []string) [][]string {
+	result := [][]string{}
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		key, value := doc.Content[i], doc.Content[i+1]
+		path := append(append([]string{}, parent...), key.Value)
+		if value.Kind != yaml.MappingNode {
+			result = append(result, path)
+			continue
+		}
+		if containsString(mergeKeys, strings.Join(path, ".")) {
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				result = append(result, append(append([]string{}, path...), value.Content[j].Value))
+			}
+			continue
+		}
+		nested := false
+		for _, mergeKey := range mergeKeys {
+			if strings.HasPrefix(mergeKey, strings.Join(path, ".")+".") {
+				nested = true
+			}
+		}
+		if nested {
+			result = append(result, getMergeEntriesIn(value, path, mergeKeys)...)
+		} else {
+			result = append(result, path)
+		}
+	}
+	return result
+}
+
+//returns the marker comment for the owned entries
+func getMergeMarker(entries [][]string) string {
+	paths := []string{}
+	for _, entry := range entries {
+		paths = append(paths, strings.Join(entry, "."))
+	}
+	return mergeMarker + " " + strings.Join(paths, ", ")
+}
+
+//removes the marker comment from a file's contents
+func removeMergeMarker(contents string) string {
+	lines := []string{}
+	for _, line := range strings.Split(contents, "\n") {
+		if !strings.HasPrefix(line, mergeMarker) {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+//returns the owned entries recorded in an existing file's marker comment
+func parseMergeMarker(contents string, mergeKeys []string) [][]string {
+	result := [][]string{}
+	for _, line := range strings.Split(contents, "\n") {
+		if !strings.HasPrefix(line, mergeMarker) {
+			continue
+		}
+		for _, path := range strings.Split(strings.TrimPrefix(line, mergeMarker), ",") {
+			path = strings.TrimSpace(path)
+			if path == "" {
+				continue
+			}
+			//keys can contain dots so they're split using the known merge keys
+			entry := []string{path}
+			for _, mergeKey := range mergeKeys {
+				if strings.HasPrefix(path, mergeKey+".") {
+					entry = append(strings.Split(mergeKey, "."), strings.TrimPrefix(path, mergeKey+"."))
+				}
+			}
+			result = append(result, entry)
+		}
+		break
+	}
+	return result
+}
+
+func containsPath(paths [][]string, path []string) bool {
+	for _, p := range paths {
+		if reflect.DeepEqual(p, path) {
+			return true
+		}
+	}
+	return false
+}
+
+//returns the value of a key in a mapping node
+func getValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+//sets the value of a key in a mapping node, appending the key if it doesn't exist yet
+//(the existing key node is kept along with its comments)
+func setValue(mapping *yaml.Node, key *yaml.Node, value *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key.Value {
+			mapping.Content[i+1] = value
+			return
+		}
+	}
+	mapping.Content = append(mapping.Content, key, value)
+}
+
+func deletePath(mapping *yaml.Node, path []string) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			mapping.Content = append(mapping.Content[:i], mapping.Content[i+2:]...)
+			return
+		}
+		if mapping.Content[i+1].Kind == yaml.MappingNode {
+			deletePath(mapping.Content[i+1], path[1:])
+		}
+		return
+	}
+}
+
+//appends the generated items that an existing list is missing
+func unionSequences(existing *yaml.Node, generated *yaml.Node) {
+	for _, item := range generated.Content {
+		found := false
+		for _, e := range existing.Content {
+			if equalNodes(e, item) {
+				found = true
+			}
+		}
+		if !found {
+			existing.Content = append(existing.Content, item)
+		}
+	}
+}
+
+//clears the tag of merge keys (<<), which would otherwise be encoded as "!!merge <<"
+func untagMergeKeys(node *yaml.Node) {
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!merge" {
+		node.Tag = ""
+	}
+	for _, child := range node.Content {
+		untagMergeKeys(child)
+	}
+}
+
+//returns true if two nodes have the same values (ignoring comments and style)
+func equalNodes(a *yaml.Node, b *yaml.Node) bool {
+	if a.Kind == yaml.AliasNode {
+		a = a.Alias
+	}
+	if b.Kind == yaml.AliasNode {
+		b = b.Alias
+	}
+	if a.Kind != b.Kind || a.Value != b.Value || len(a.Content) != len(b.Content) {
+		return false
+	}
+	for i := range a.Content {
+		if !equalNodes(a.Content[i], b.Content[i]) {
+			return false
+		}
+	}
+	return true
+}
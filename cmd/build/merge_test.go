@@ -0,0 +1,311 @@
+package build
+
+import (
+	"strings"
+	"testing"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+const mergeExisting = `# my pipeline
+image: node:10
+stages:
+  - test
+  - build
+variables:
+  GO_VERSION: "1.12"
+defaults: &defaults
+  tags:
+    - docker
+test:
+  <<: *defaults
+  stage: test
+  script:
+    - go test ./... # run the tests
+`
+
+const mergeGenerated = `image: quay.io/turner/fargate-cicd
+stages:
+  - build
+  - deploy
+variables:
+  AWS_DEFAULT_REGION: us-east-1
+build:
+  stage: build
+  script:
+    - docker build .
+deploy:
+  stage: deploy
+  script:
+    - fargate service deploy
+`
+
+func getMerged(t *testing.T, existing string, generated string, mergeKeys ...string) (string, *yaml.Node) {
+	artifact := createYAMLArtifact(".gitlab-ci.yml", generated, mergeKeys...)
+	merged, err := MergeYAML(existing, artifact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := parseYAMLDocument(merged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return merged, doc.Content[0]
+}
+
+//returns the value at a path in a mapping node
+func getNode(doc *yaml.Node, path ...string) *yaml.Node {
+	for _, key := range path {
+		if doc == nil {
+			return nil
+		}
+		doc = getValue(doc, key)
+	}
+	return doc
+}
+
+func TestMergeYAML(t *testing.T) {
+	merged, doc := getMerged(t, mergeExisting, mergeGenerated, "variables")
+	t.Log(merged)
+
+	//user entries are kept
+	if getNode(doc, "test") == nil {
+		t.Error("expecting test job")
+	}
+	if getNode(doc, "variables", "GO_VERSION").Value != "1.12" {
+		t.Error("expecting GO_VERSION variable")
+	}
+
+	//values set by the user aren't rewritten
+	if getNode(doc, "image").Value != "node:10" {
+		t.Errorf("expected: %s; actual: %s", "node:10", getNode(doc, "image").Value)
+	}
+
+	//generated entries are added
+	if getNode(doc, "variables", "AWS_DEFAULT_REGION").Value != "us-east-1" {
+		t.Error("expecting AWS_DEFAULT_REGION variable")
+	}
+	if getNode(doc, "build") == nil || getNode(doc, "deploy") == nil {
+		t.Error("expecting build and deploy jobs")
+	}
+
+	//user stages are kept
+	stages := []string{}
+	for _, stage := range getNode(doc, "stages").Content {
+		stages = append(stages, stage.Value)
+	}
+	expected := "test,build,deploy"
+	if strings.Join(stages, ",") != expected {
+		t.Errorf("expected: %s; actual: %s", expected, strings.Join(stages, ","))
+	}
+
+	//comments and anchors are preserved
+	for _, e := range []string{"# my pipeline", "# run the tests", "&defaults", "\n  <<: *defaults"} {
+		if !strings.Contains(merged, e) {
+			t.Error("expecting", e)
+		}
+	}
+
+	//owned entries are recorded
+	expectedMarker := mergeMarker + " variables.AWS_DEFAULT_REGION, build, deploy"
+	if !strings.HasPrefix(merged, expectedMarker+"\n") {
+		t.Errorf("expected: %s; actual: %s", expectedMarker, strings.Split(merged, "\n")[0])
+	}
+}
+
+func TestMergeYAMLBlankLines(t *testing.T) {
+	existing := `# my pipeline
+stages:
+  - test
+
+# the tests
+test:
+  stage: test
+  script:
+    - go test ./...
+
+lint:
+  stage: test
+  script:
+    - go vet ./...
+`
+	merged, _ := getMerged(t, existing, mergeGenerated, "variables")
+	t.Log(merged)
+
+	//the user's blank lines are kept and generated entries are spaced like the template
+	expected := []string{
+		"  - deploy\n\n# the tests\ntest:\n",
+		"    - go test ./...\n\nlint:\n",
+		"    - go vet ./...\nimage:",
+		"\nbuild:\n",
+	}
+	for _, e := range expected {
+		if !strings.Contains(merged, e) {
+			t.Error("expecting", e)
+		}
+	}
+}
+
+func TestMergeYAMLUpdatesOwnedEntries(t *testing.T) {
+	merged, _ := getMerged(t, mergeExisting, mergeGenerated, "variables")
+
+	//the build job is owned so it's updated, deploy is no longer generated so it's removed
+	generated := strings.Replace(strings.Split(mergeGenerated, "deploy:\n  stage")[0], "docker build .", "docker build -f Dockerfile .", 1)
+	merged, doc := getMerged(t, merged, generated, "variables")
+	t.Log(merged)
+
+	if !strings.Contains(merged, "docker build -f Dockerfile .") {
+		t.Error("expecting build job to be updated")
+	}
+	if getNode(doc, "deploy") != nil {
+		t.Error("not expecting deploy job")
+	}
+	if getNode(doc, "test") == nil {
+		t.Error("expecting test job")
+	}
+}
+
+func TestMergeYAMLReplacesJobs(t *testing.T) {
+	existing := `version: 2.1
+orbs:
+  node: circleci/node@5
+jobs:
+  build:
+    docker:
+      - image: node
+    steps:
+      - run: npm run build
+  test:
+    docker:
+      - image: node
+    steps:
+      - run: npm test
+workflows:
+  main:
+    jobs:
+      - test
+`
+	generated := `version: 2
+jobs:
+  build:
+    docker:
+      - image: quay.io/turner/fargate-cicd
+    steps:
+      - run: docker build .
+workflows:
+  version: 2
+  build-deploy:
+    jobs:
+      - build
+`
+	merged, doc := getMerged(t, existing, generated, circleCIv2MergeKeys...)
+	t.Log(merged)
+
+	//the user's version isn't downgraded and their orbs are kept
+	if getNode(doc, "version").Value != "2.1" {
+		t.Errorf("expected: %s; actual: %s", "2.1", getNode(doc, "version").Value)
+	}
+	if getNode(doc, "orbs", "node") == nil {
+		t.Error("expecting orbs")
+	}
+
+	//jobs (the children of merge keys) with the same name are replaced
+	if !strings.Contains(merged, "docker build .") || strings.Contains(merged, "npm run build") {
+		t.Error("expecting build job to be replaced")
+	}
+	if getNode(doc, "jobs", "test") == nil {
+		t.Error("expecting test job")
+	}
+
+	//the build job is run by a workflow alongside the user's
+	if getNode(doc, "workflows", "main") == nil || getNode(doc, "workflows", "build-deploy") == nil {
+		t.Error("expecting both workflows")
+	}
+}
+
+func TestMergeYAMLUnchanged(t *testing.T) {
+	merged, _ := getMerged(t, mergeExisting, mergeGenerated, "variables")
+	again, _ := getMerged(t, merged, mergeGenerated, "variables")
+	if merged != again {
+		t.Errorf("expected: %s; actual: %s", merged, again)
+	}
+}
+
+func TestMergeYAMLNewFile(t *testing.T) {
+	merged, _ := getMerged(t, "", mergeGenerated, "variables")
+	expectedMarker := mergeMarker + " image, stages, variables.AWS_DEFAULT_REGION, build, deploy"
+	if !strings.HasPrefix(merged, expectedMarker+"\n") {
+		t.Errorf("expected: %s; actual: %s", expectedMarker, strings.Split(merged, "\n")[0])
+	}
+	again, _ := getMerged(t, merged, mergeGenerated, "variables")
+	if again != merged {
+		t.Errorf("expected: %s; actual: %s", merged, again)
+	}
+
+	//the user's stages are kept when owned stages are updated
+	merged = strings.Replace(merged, "  - deploy\n", "  - deploy\n  - test\n", 1)
+	_, doc := getMerged(t, merged, mergeGenerated, "variables")
+	if len(getNode(doc, "stages").Content) != 3 {
+		t.Error("expecting test stage")
+	}
+}
+
+func TestMergeYAMLOnKey(t *testing.T) {
+	existing := `name: ci
+on:
+  push:
+    branches: [main]
+jobs:
+  test:
+    runs-on: ubuntu-latest
+    steps:
+      - run: go test ./...
+`
+	generated := `name: dev
+on:
+  push:
+    branches:
+      - develop
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    steps:
+      - run: docker build .
+`
+	artifact := createYAMLArtifact(".github/workflows/dev.yml", generated, githubActionsMergeKeys...)
+	merged, err := MergeYAML(existing, artifact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(merged)
+
+	//on: isn't a boolean and the user's triggers and name are kept
+	expected := []string{"\non:\n", "branches: [main]", "name: ci"}
+	for _, e := range expected {
+		if !strings.Contains(merged, e) {
+			t.Error("expecting", e)
+		}
+	}
+	if strings.Contains(merged, "true:") {
+		t.Error("expecting on key to be kept")
+	}
+	err = ValidateArtifact(&Artifact{FilePath: artifact.FilePath, FileContents: merged})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMergeYAMLNotMergeable(t *testing.T) {
+	_, err := MergeYAML(mergeExisting, createArtifact("build.sh", "#!/bin/bash"))
+	if err == nil {
+		t.Error("expecting error")
+	}
+	_, err = MergeYAML("not: [valid", createYAMLArtifact(".gitlab-ci.yml", mergeGenerated))
+	if err == nil {
+		t.Error("expecting error")
+	}
+	_, err = MergeYAML("- a list", createYAMLArtifact(".gitlab-ci.yml", mergeGenerated))
+	if err == nil {
+		t.Error("expecting error")
+	}
+}
@@ -0,0 +1,135 @@
+package build
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProvider_TemplateOverride(t *testing.T) {
+
+	//arrange
+	dir, err := ioutil.TempDir("", "fargate-create-templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx := mockContext{
+		App:     "my-app",
+		Env:     "dev",
+		Account: "123456789",
+		Region:  "us-west-1",
+	}
+
+	for _, name := range builtInProviders {
+		provider, err := GetProvider(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		//export the built-in templates
+		exporter, ok := provider.(TemplateExporter)
+		if !ok {
+			t.Fatalf("expecting %s to export templates", name)
+		}
+		for _, artifact := range exporter.ExportTemplates() {
+			file := filepath.Join(dir, name, artifact.FilePath)
+			err = os.MkdirAll(filepath.Dir(file), 0755)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = ioutil.WriteFile(file, []byte(artifact.FileContents), artifact.FileMode)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		//act
+		override, err := GetTemplateOverride(dir, name, Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if override == nil {
+			t.Fatalf("expecting an override for %s", name)
+		}
+		expected, err := provider.ProvideArtifacts(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual, err := override.ProvideArtifacts(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		//assert
+		//unchanged templates render the same artifacts as the built-in provider
+		if len(actual) != len(expected) {
+			t.Fatalf("%s expected: %d; actual: %d", name, len(expected), len(actual))
+		}
+		for _, e := range expected {
+			found := false
+			for _, a := range actual {
+				if a.FilePath == e.FilePath {
+					found = true
+					if strings.TrimSpace(a.FileContents) != strings.TrimSpace(e.FileContents) {
+						t.Errorf("expected: %s; actual: %s", e.FileContents, a.FileContents)
+					}
+					if a.FileMode != e.FileMode {
+						t.Errorf("expected: %v; actual: %v", e.FileMode, a.FileMode)
+					}
+					if a.Merge != e.Merge || strings.Join(a.MergeKeys, ",") != strings.Join(e.MergeKeys, ",") {
+						t.Errorf("%s expected: %v %v; actual: %v %v", e.FilePath, e.Merge, e.MergeKeys, a.Merge, a.MergeKeys)
+					}
+				}
+			}
+			if !found {
+				t.Errorf("%s expected: %s", name, e.FilePath)
+			}
+		}
+	}
+
+	if override, _ := GetTemplateOverride(dir, "doesnotexist", Options{}); override != nil {
+		t.Error("not expecting an override")
+	}
+}
+
+func TestProvider_TemplateOverrideOIDC(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fargate-create-templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	err = os.MkdirAll(filepath.Join(dir, "githubactions"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//the customized templates would silently ignore the role
+	options := []Options{
+		{OIDC: true},
+		{OIDC: true, RoleARN: "arn:aws:iam::123456789:role/my-app-cicd"},
+		{OIDC: true, Terraform: true},
+	}
+	for _, o := range options {
+		_, err := GetTemplateOverride(dir, "githubactions", o)
+		if err == nil {
+			t.Error("expecting error", o)
+			continue
+		}
+		//the error explains how to fix it
+		for _, e := range []string{"remove those flags", "delete " + filepath.Join(dir, "githubactions")} {
+			if !strings.Contains(err.Error(), e) {
+				t.Error("expecting", e, err)
+			}
+		}
+	}
+
+	//without a customized template the built-in provider is used
+	override, err := GetTemplateOverride(dir, "gitlabci", Options{OIDC: true})
+	if override != nil || err != nil {
+		t.Error("not expecting an override", err)
+	}
+}
@@ -0,0 +1,279 @@
+package build
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+//pluginPrefix is prepended to a provider's name to find its plugin
+const pluginPrefix = "fargate-create-build-"
+
+//returns a directory in the user's fargate-create config directory
+func configDir(name string) string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "fargate-create", name)
+}
+
+//PluginDir returns the directory where build provider plugins are installed
+func PluginDir() string {
+	return configDir("plugins")
+}
+
+//TemplatesDir returns the default directory of customized provider templates
+func TemplatesDir() string {
+	return configDir("build")
+}
+
+//GetTemplateOverride returns a provider that renders the customized templates
+//for a provider in a templates directory (<dir>/<provider>/), if they exist.
+//The exported templates use static keys so options that change the
+//generated files (e.g., OIDC) can't be used with them.
+func GetTemplateOverride(dir string, provider string, options Options) (Provider, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	path := filepath.Join(dir, strings.ToLower(provider))
+	if info, err := os.Stat(path); err != nil || !info.IsDir() {
+		return nil, nil
+	}
+	if options.OIDC || options.RoleARN != "" || options.Terraform {
+		return nil, fmt.Errorf("the provider templates in %s don't support --oidc, --role-arn or --terraform: remove those flags or delete %s to use the built-in provider", path, path)
+	}
+	return TemplatePlugin{Directory: path, Options: options}, nil
+}
+
+//templateMergeKeys are the merge keys of the built-in providers' YAML files (by path pattern)
+//so that the files rendered from customized templates are merged the same way
+var templateMergeKeys = []struct {
+	Pattern   string
+	MergeKeys []string
+}{
+	{"buildspec.yml", []string{"phases"}},
+	{"azure-pipelines.yml", []string{"variables"}},
+	{"bitbucket-pipelines.yml", []string{"pipelines.branches"}},
+	{".buildkite/pipeline.yml", []string{"env"}},
+	{".circleci/config.yml", circleCIv2MergeKeys},
+	{".github/workflows/*.yml", githubActionsMergeKeys},
+	{".gitlab-ci.yml", []string{"variables"}},
+}
+
+//returns the merge keys for a file path
+func getTemplateMergeKeys(filePath string) []string {
+	for _, t := range templateMergeKeys {
+		if matched, _ := path.Match(t.Pattern, filePath); matched {
+			return t.MergeKeys
+		}
+	}
+	return nil
+}
+
+//findPlugin looks for a plugin executable or template directory for a provider
+func findPlugin(provider string, options Options) Provider {
+	name := pluginPrefix + provider
+
+	//plugin directory (executable or template directory)
+	if dir := PluginDir(); dir != "" {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil {
+			if info.IsDir() {
+				return TemplatePlugin{Directory: path, Options: options}
+			}
+			if info.Mode()&0111 != 0 {
+				return ExecutablePlugin{Path: path, Provider: provider, Options: options}
+			}
+		}
+	}
+
+	//executable on the PATH
+	if path, err := exec.LookPath(name); err == nil {
+		return ExecutablePlugin{Path: path, Provider: provider, Options: options}
+	}
+
+	return nil
+}
+
+//pluginContext is the JSON representation of a build context
+type pluginContext struct {
+	App          string `json:"app"`
+	Environment  string `json:"environment"`
+	Account      string `json:"account"`
+	Region       string `json:"region"`
+	TemplateType string `json:"templateType"`
+	Profile      string `json:"profile,omitempty"`
+}
+
+func getPluginContext(context Context) pluginContext {
+	c := getContextTemplate(context, Options{})
+	return pluginContext{
+		App:          c.App,
+		Environment:  c.Env,
+		Account:      c.Account,
+		Region:       c.Region,
+		TemplateType: c.TemplateType,
+		Profile:      c.Profile,
+	}
+}
+
+//PluginRequest is written as JSON to a plugin's stdin
+type PluginRequest struct {
+	Provider string          `json:"provider"`
+	Context  pluginContext   `json:"context"`
+	Contexts []pluginContext `json:"contexts,omitempty"`
+	Options  Options         `json:"options"`
+}
+
+//PluginResponse is read as JSON from a plugin's stdout
+type PluginResponse struct {
+	Artifacts []*Artifact `json:"artifacts"`
+	Error     string      `json:"error,omitempty"`
+}
+
+//ExecutablePlugin is a build provider implemented by an external executable
+//that receives a PluginRequest on stdin and returns a PluginResponse on stdout
+type ExecutablePlugin struct {
+	Path     string
+	Provider string
+	Options  Options
+}
+
+//ProvideArtifacts is the Provider implementation
+func (plugin ExecutablePlugin) ProvideArtifacts(context Context) ([]*Artifact, error) {
+	return plugin.run(PluginRequest{
+		Provider: plugin.Provider,
+		Context:  getPluginContext(context),
+		Options:  plugin.Options,
+	})
+}
+
+//ProvidePromotionArtifacts is the PromotionProvider implementation
+func (plugin ExecutablePlugin) ProvidePromotionArtifacts(contexts []Context) ([]*Artifact, error) {
+	if len(contexts) == 0 {
+		return nil, errors.New("at least one environment is required")
+	}
+	request := PluginRequest{
+		Provider: plugin.Provider,
+		Context:  getPluginContext(contexts[0]),
+		Options:  plugin.Options,
+	}
+	for _, context := range contexts {
+		request.Contexts = append(request.Contexts, getPluginContext(context))
+	}
+	return plugin.run(request)
+}
+
+func (plugin ExecutablePlugin) run(request PluginRequest) ([]*Artifact, error) {
+	input, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	//messages written to stderr are shown to the user
+	var stdout bytes.Buffer
+	cmd := exec.Command(plugin.Path)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("build plugin %s failed: %v", plugin.Path, err)
+	}
+
+	var response PluginResponse
+	if err := json.Unmarshal(stdout.Bytes(), &response); err != nil {
+		return nil, fmt.Errorf("build plugin %s returned invalid output: %v", plugin.Path, err)
+	}
+	if response.Error != "" {
+		return nil, fmt.Errorf("build plugin %s: %s", plugin.Path, response.Error)
+	}
+	for _, artifact := range response.Artifacts {
+		if err := validatePluginArtifact(artifact); err != nil {
+			return nil, fmt.Errorf("build plugin %s: %v", plugin.Path, err)
+		}
+	}
+	return response.Artifacts, nil
+}
+
+//TemplatePlugin is a build provider implemented by a directory of templates
+//where each file (and its path) is rendered using the build context
+type TemplatePlugin struct {
+	Directory string
+	Options   Options
+}
+
+//ProvideArtifacts is the Provider implementation
+func (plugin TemplatePlugin) ProvideArtifacts(context Context) ([]*Artifact, error) {
+	contextTemplate := getContextTemplate(context, plugin.Options)
+	artifacts := []*Artifact{}
+	err := filepath.Walk(plugin.Directory, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(plugin.Directory, path)
+		if err != nil {
+			return err
+		}
+		dat, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		contents, err := renderTemplate(string(dat), contextTemplate)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		filePath, err := renderTemplate(filepath.ToSlash(rel), contextTemplate)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		artifact := &Artifact{
+			FilePath:     filePath,
+			FileContents: contents,
+			FileMode:     info.Mode().Perm(),
+		}
+
+		//YAML files are merged into existing files like the built-in providers' files
+		ext := strings.ToLower(filepath.Ext(filePath))
+		if ext == ".yml" || ext == ".yaml" {
+			if _, err := parseYAMLDocument(contents); err == nil {
+				artifact.Merge = true
+				artifact.MergeKeys = getTemplateMergeKeys(filePath)
+			}
+		}
+		if err := validatePluginArtifact(artifact); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		artifacts = append(artifacts, artifact)
+		return nil
+	})
+	return artifacts, err
+}
+
+//plugins can only write files relative to the current directory
+func validatePluginArtifact(artifact *Artifact) error {
+	if artifact.FilePath == "" {
+		return errors.New("artifact is missing a filePath")
+	}
+	clean := filepath.Clean(artifact.FilePath)
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("artifact path is outside of the current directory: %s", artifact.FilePath)
+	}
+	if artifact.FileMode == 0 {
+		artifact.FileMode = 0644
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package build
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProvider_ExecutablePlugin(t *testing.T) {
+
+	//arrange
+	dir, err := ioutil.TempDir("", "fargate-create-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	t.Setenv("HOME", dir)
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	requestFile := filepath.Join(dir, "request.json")
+	script := `#!/bin/sh
+cat > ` + requestFile + `
+echo '{"artifacts":[{"filePath":"foo.yml","fileContents":"foo: bar"}]}'
+`
+	err = ioutil.WriteFile(filepath.Join(dir, "fargate-create-build-foo"), []byte(script), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := mockContext{
+		App:     "my-app",
+		Env:     "dev",
+		Account: "123456789",
+		Region:  "us-west-1",
+	}
+
+	//act
+	provider, err := GetProvider("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	artifacts, err := provider.ProvideArtifacts(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//assert
+	if len(artifacts) != 1 {
+		t.Fatalf("expected: %d; actual: %d", 1, len(artifacts))
+	}
+	if artifacts[0].FilePath != "foo.yml" {
+		t.Errorf("expected: %s; actual: %s", "foo.yml", artifacts[0].FilePath)
+	}
+	if artifacts[0].FileMode != 0644 {
+		t.Errorf("expected: %v; actual: %v", os.FileMode(0644), artifacts[0].FileMode)
+	}
+
+	dat, err := ioutil.ReadFile(requestFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var request PluginRequest
+	err = json.Unmarshal(dat, &request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(string(dat))
+	if request.Provider != "foo" {
+		t.Errorf("expected: %s; actual: %s", "foo", request.Provider)
+	}
+	if request.Context.Environment != ctx.Env {
+		t.Errorf("expected: %s; actual: %s", ctx.Env, request.Context.Environment)
+	}
+	if request.Context.TemplateType != TemplateTypeService {
+		t.Errorf("expected: %s; actual: %s", TemplateTypeService, request.Context.TemplateType)
+	}
+}
+
+func TestProvider_ExecutablePluginInvalidPath(t *testing.T) {
+	err := validatePluginArtifact(&Artifact{FilePath: "../foo.yml"})
+	if err == nil {
+		t.Error("expecting error")
+	}
+	err = validatePluginArtifact(&Artifact{FilePath: "/etc/foo.yml"})
+	if err == nil {
+		t.Error("expecting error")
+	}
+}
+
+func TestProvider_TemplatePlugin(t *testing.T) {
+
+	//arrange
+	dir, err := ioutil.TempDir("", "fargate-create-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	t.Setenv("HOME", dir)
+
+	pluginDir := filepath.Join(PluginDir(), "fargate-create-build-bar")
+	err = os.MkdirAll(filepath.Join(pluginDir, ".bar"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := "cluster: {{ .App }}-{{ .Env }}\ndeploy: {{ .DeployCommand \"$IMAGE\" }}\n"
+	err = ioutil.WriteFile(filepath.Join(pluginDir, ".bar", "pipeline.yml"), []byte(template), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := mockContext{
+		App:     "my-app",
+		Env:     "dev",
+		Account: "123456789",
+		Region:  "us-west-1",
+	}
+
+	//act
+	provider, err := GetProvider("bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	artifacts, err := provider.ProvideArtifacts(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//assert
+	if len(artifacts) != 1 {
+		t.Fatalf("expected: %d; actual: %d", 1, len(artifacts))
+	}
+	if artifacts[0].FilePath != ".bar/pipeline.yml" {
+		t.Errorf("expected: %s; actual: %s", ".bar/pipeline.yml", artifacts[0].FilePath)
+	}
+	t.Log(artifacts[0].FileContents)
+	if !strings.Contains(artifacts[0].FileContents, "cluster: my-app-dev") {
+		t.Error("expecting cluster: my-app-dev")
+	}
+	if !strings.Contains(artifacts[0].FileContents, "deploy: fargate service deploy -i $IMAGE") {
+		t.Error("expecting deploy command")
+	}
+}
+
+func TestProvider_NotSupported(t *testing.T) {
+	t.Setenv("HOME", os.TempDir())
+	_, err := GetProvider("doesnotexist")
+	if err == nil {
+		t.Error("expecting error")
+	}
+}
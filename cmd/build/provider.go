@@ -2,15 +2,24 @@ package build
 
 import (
 	"errors"
+	"fmt"
 	"os"
+	"path"
+	"path/filepath"
 	"strings"
 )
 
 //Artifact represents a build artifact
 type Artifact struct {
-	FilePath     string
-	FileContents string
-	FileMode     os.FileMode
+	FilePath     string      `json:"filePath"`
+	FileContents string      `json:"fileContents"`
+	FileMode     os.FileMode `json:"fileMode,omitempty"`
+
+	//Merge allows the artifact (YAML) to be merged into an existing file (see MergeYAML)
+	Merge bool `json:"merge,omitempty"`
+
+	//MergeKeys are the keys whose children are merged individually (e.g., jobs)
+	MergeKeys []string `json:"mergeKeys,omitempty"`
 }
 
 //Context represents a build context
@@ -19,22 +28,263 @@ type Context interface {
 	GetEnvironment() string
 	GetAccount() string
 	GetRegion() string
+	GetTemplateType() string
+	GetProfile() string
 }
 
+//the template types that build providers know how to deploy
+const (
+	//TemplateTypeService is a long running ECS service
+	TemplateTypeService = "Service"
+
+	//TemplateTypeScheduledTask is an ECS task that runs on a CloudWatch Events schedule
+	TemplateTypeScheduledTask = "ScheduledTask"
+)
+
+//the image versioning strategies
+const (
+	//VersioningStatic uses a fixed version that's managed in the build artifacts
+	VersioningStatic = "static"
+
+	//VersioningSemverFile reads the version from a file (e.g., VERSION)
+	VersioningSemverFile = "semver"
+
+	//VersioningPackageJSON reads the version from package.json
+	VersioningPackageJSON = "package.json"
+
+	//VersioningGitTag uses the most recent git tag
+	VersioningGitTag = "git-tag"
+
+	//VersioningCommitSHA uses the short commit sha
+	VersioningCommitSHA = "sha"
+)
+
+const defaultVersion = "0.1.0"
+const defaultVersionFile = "VERSION"
+const defaultBranch = "develop"
+const defaultInfrastructureDir = "iac"
+const defaultScanSeverity = "HIGH"
+
+//SBOMFile is the software bill of materials generated when scanning images
+const SBOMFile = "sbom.spdx.json"
+
+//ScanSeverities are the vulnerability severities reported by scans (lowest to highest)
+var ScanSeverities = []string{"UNKNOWN", "LOW", "MEDIUM", "HIGH", "CRITICAL"}
+
 type contextTemplate struct {
-	App     string
-	Env     string
-	Account string
-	Region  string
+	App          string
+	Env          string
+	Account      string
+	Region       string
+	TemplateType string
+	Profile      string
+
+	//Branches trigger the pipeline
+	Branches []string
+
+	//Version is a shell expression that evaluates to the image version
+	Version string
+
+	//VersionScript is a shell command that prints the image version ("" for static versions)
+	VersionScript string
+
+	Dockerfile    string
+	DockerContext string
+	BuildArgs     []string
+
+	//Platforms are the target platforms of a multi-arch image (built and pushed using docker buildx)
+	Platforms []string
+
+	//Deploy deploys the image after it's pushed (local builds only, pipelines always deploy)
+	Deploy bool
+
+	//IaC adds jobs that plan/apply the environment's terraform
+	IaC bool
+
+	//InfrastructureDir is the repository relative directory of the scaffolded terraform
+	InfrastructureDir string
+
+	//Backend is the environment's terraform backend configuration
+	Backend Backend
+
+	//Scan scans images for vulnerabilities and generates an SBOM before they're pushed
+	Scan bool
+
+	//ScanSeverity is the lowest vulnerability severity that fails a scan
+	ScanSeverity string
+}
+
+func getContextTemplate(context Context, options Options) contextTemplate {
+	templateType := context.GetTemplateType()
+	if templateType == "" {
+		templateType = TemplateTypeService
+	}
+	result := contextTemplate{
+		App:           context.GetApp(),
+		Env:           context.GetEnvironment(),
+		Account:       context.GetAccount(),
+		Region:        context.GetRegion(),
+		TemplateType:  templateType,
+		Profile:       context.GetProfile(),
+		Branches:      options.Branches,
+		Version:       defaultVersion,
+		VersionScript: getVersionScript(options),
+		Dockerfile:    options.Dockerfile,
+		DockerContext: options.DockerContext,
+		BuildArgs:     options.BuildArgs,
+		Platforms:     options.Platforms,
+		Deploy:        options.Deploy,
+		IaC:           options.IaC,
+		Backend:       options.Backends[context.GetEnvironment()],
+		Scan:          options.Scan,
+		ScanSeverity:  strings.ToUpper(options.ScanSeverity),
+	}
+	if result.ScanSeverity == "" {
+		result.ScanSeverity = defaultScanSeverity
+	}
+	result.InfrastructureDir = filepath.ToSlash(filepath.Clean(options.InfrastructureDir))
+	if options.InfrastructureDir == "" {
+		result.InfrastructureDir = defaultInfrastructureDir
+	}
+	if len(result.Branches) == 0 {
+		result.Branches = []string{defaultBranch}
+	}
+	if result.VersionScript != "" {
+		result.Version = fmt.Sprintf("$(%s)", result.VersionScript)
+	}
+	if result.DockerContext == "" {
+		result.DockerContext = "."
+	}
+	return result
+}
+
+//returns the shell command that prints the image version for a versioning strategy
+func getVersionScript(options Options) string {
+	switch options.Versioning {
+	case VersioningSemverFile:
+		file := options.VersionFile
+		if file == "" {
+			file = defaultVersionFile
+		}
+		return "cat " + file
+	case VersioningPackageJSON:
+		return "jq -r .version < package.json"
+	case VersioningGitTag:
+		return "git describe --tags --abbrev=0"
+	case VersioningCommitSHA:
+		return "git rev-parse --short HEAD"
+	}
+	return ""
+}
+
+//NeedsGitTags returns true if the version is derived from the repository's tags
+//(shallow clones need to fetch the full history)
+func (c contextTemplate) NeedsGitTags() bool {
+	return c.VersionScript == getVersionScript(Options{Versioning: VersioningGitTag})
+}
+
+//BranchPattern returns a glob that matches the trigger branches
+func (c contextTemplate) BranchPattern() string {
+	if len(c.Branches) == 1 {
+		return c.Branches[0]
+	}
+	return "{" + strings.Join(c.Branches, ",") + "}"
+}
+
+//BranchList returns the trigger branches separated by spaces
+func (c contextTemplate) BranchList() string {
+	return strings.Join(c.Branches, " ")
 }
 
-func getContextTemplate(context Context) contextTemplate {
-	return contextTemplate{
-		App:     context.GetApp(),
-		Env:     context.GetEnvironment(),
-		Account: context.GetAccount(),
-		Region:  context.GetRegion(),
+//DockerBuildCommand returns the docker command that builds an image
+//(multi-arch images are pushed as they're built since they can't be loaded locally)
+func (c contextTemplate) DockerBuildCommand(image string) string {
+	cmd := "docker build"
+	if len(c.Platforms) > 0 {
+		cmd = "docker buildx build --platform " + strings.Join(c.Platforms, ",")
 	}
+	if c.Dockerfile != "" {
+		cmd += " -f " + c.Dockerfile
+	}
+	for _, arg := range c.BuildArgs {
+		cmd += " --build-arg " + arg
+	}
+	if len(c.Platforms) > 0 {
+		cmd += " --push"
+	}
+	return fmt.Sprintf("%s -t %s %s", cmd, image, c.DockerContext)
+}
+
+//EnvironmentDir returns the repository relative directory of the environment's terraform
+func (c contextTemplate) EnvironmentDir() string {
+	return path.Join(c.InfrastructureDir, "env", c.Env)
+}
+
+//BackendConfig returns the terraform init arguments that configure the environment's backend
+//(the profile used when scaffolding is cleared since pipelines use their own credentials)
+func (c contextTemplate) BackendConfig() string {
+	result := ""
+	if c.Backend.Bucket != "" {
+		result += " -backend-config=bucket=" + c.Backend.Bucket
+	}
+	if c.Backend.Key != "" {
+		result += " -backend-config=key=" + c.Backend.Key
+	}
+	if c.Backend.Region != "" {
+		result += " -backend-config=region=" + c.Backend.Region
+	}
+	return strings.TrimSpace(result + " -backend-config=profile=")
+}
+
+//ScanCommand returns the docker command that scans an image for vulnerabilities
+//(using trivy), failing if any are found at or above the scan severity
+func (c contextTemplate) ScanCommand(image string) string {
+	return fmt.Sprintf("docker run --rm -v /var/run/docker.sock:/var/run/docker.sock aquasec/trivy:latest image --no-progress --exit-code 1 --severity %s %s",
+		c.ScanSeverities(), image)
+}
+
+//ScanSeverities returns the vulnerability severities that fail a scan (e.g., HIGH,CRITICAL)
+func (c contextTemplate) ScanSeverities() string {
+	for i, severity := range ScanSeverities {
+		if severity == c.ScanSeverity {
+			return strings.Join(ScanSeverities[i:], ",")
+		}
+	}
+	return ""
+}
+
+//SBOMFile returns the file that the SBOM is written to
+func (c contextTemplate) SBOMFile() string {
+	return SBOMFile
+}
+
+//SBOMCommand returns the docker command that generates an image's SBOM (using syft)
+func (c contextTemplate) SBOMCommand(image string) string {
+	return fmt.Sprintf("docker run --rm -v /var/run/docker.sock:/var/run/docker.sock anchore/syft:latest %s -o spdx-json > %s", image, SBOMFile)
+}
+
+//IsScheduledTask returns true if the context is a scheduled task
+func (c contextTemplate) IsScheduledTask() bool {
+	return c.TemplateType == TemplateTypeScheduledTask
+}
+
+//DeployCommand returns the fargate cli command that deploys an image
+//to the context's template type
+func (c contextTemplate) DeployCommand(image string) string {
+	if c.IsScheduledTask() {
+		//register a new task definition revision and point the schedule at it
+		return fmt.Sprintf("fargate events target -r $(fargate task register -i %s)", image)
+	}
+	return "fargate service deploy -i " + image
+}
+
+//ValidateContext returns an error if build providers can't deploy the context
+func ValidateContext(context Context) error {
+	templateType := context.GetTemplateType()
+	if templateType == "" || templateType == TemplateTypeService || templateType == TemplateTypeScheduledTask {
+		return nil
+	}
+	return fmt.Errorf("build providers do not support template type: %s", templateType)
 }
 
 func createArtifact(filePath string, fileContents string) *Artifact {
@@ -45,29 +295,235 @@ func createArtifact(filePath string, fileContents string) *Artifact {
 	}
 }
 
+//createYAMLArtifact creates an artifact that can be merged into an existing file
+func createYAMLArtifact(filePath string, fileContents string, mergeKeys ...string) *Artifact {
+	artifact := createArtifact(filePath, fileContents)
+	artifact.Merge = true
+	artifact.MergeKeys = mergeKeys
+	return artifact
+}
+
+//promotionTemplate is the data used to render a pipeline that builds an image
+//once (in the first environment) and promotes it through the rest
+type promotionTemplate struct {
+	contextTemplate
+	Stages []promotionStage
+}
+
+//promotionStage is an environment in a promotion pipeline
+type promotionStage struct {
+	contextTemplate
+
+	//Previous is the environment that's promoted from ("" for the first)
+	Previous string
+
+	//RoleARN is the IAM role assumed when deploying (if any)
+	RoleARN string
+}
+
+func getPromotionTemplate(contexts []Context, options Options) (promotionTemplate, error) {
+	if len(contexts) == 0 {
+		return promotionTemplate{}, errors.New("at least one environment is required")
+	}
+	result := promotionTemplate{
+		contextTemplate: getContextTemplate(contexts[0], options),
+	}
+	previous := ""
+	for _, context := range contexts {
+		result.Stages = append(result.Stages, promotionStage{
+			contextTemplate: getContextTemplate(context, options),
+			Previous:        previous,
+		})
+		previous = context.GetEnvironment()
+	}
+	return result, nil
+}
+
+//Options represents optional build provider settings
+type Options struct {
+	//OIDC uses role assumption (rather than static keys) where supported
+	OIDC bool `json:"oidc,omitempty"`
+
+	//RoleARN is the IAM role assumed when using OIDC
+	RoleARN string `json:"roleArn,omitempty"`
+
+	//Terraform generates companion terraform for the OIDC provider and role
+	Terraform bool `json:"terraform,omitempty"`
+
+	//Repository is the source repository (e.g., my-org/my-app) trusted by the role
+	Repository string `json:"repository,omitempty"`
+
+	//InfrastructureDir is where the scaffolded terraform lives
+	InfrastructureDir string `json:"infrastructureDir,omitempty"`
+
+	//Branches trigger the pipeline (default develop)
+	Branches []string `json:"branches,omitempty"`
+
+	//Versioning is the image versioning strategy (e.g., VersioningSemverFile)
+	Versioning string `json:"versioning,omitempty"`
+
+	//VersionFile contains the version when using VersioningSemverFile (default VERSION)
+	VersionFile string `json:"versionFile,omitempty"`
+
+	//Dockerfile is the path to the Dockerfile (default Dockerfile in the docker context)
+	Dockerfile string `json:"dockerfile,omitempty"`
+
+	//DockerContext is the docker build context directory (default .)
+	DockerContext string `json:"dockerContext,omitempty"`
+
+	//BuildArgs are passed to docker build (e.g., FOO=bar)
+	BuildArgs []string `json:"buildArgs,omitempty"`
+
+	//Platforms builds a multi-arch image for these platforms (e.g., linux/amd64,linux/arm64)
+	Platforms []string `json:"platforms,omitempty"`
+
+	//Deploy deploys the image using the fargate cli after it's pushed (local only)
+	Deploy bool `json:"deploy,omitempty"`
+
+	//IaC generates pipelines that plan/apply the environments' terraform
+	IaC bool `json:"iac,omitempty"`
+
+	//Backends are the terraform backend configurations of the environments (keyed by environment)
+	Backends map[string]Backend `json:"backends,omitempty"`
+
+	//Scan adds steps that scan images for vulnerabilities and generate an SBOM before they're pushed
+	Scan bool `json:"scan,omitempty"`
+
+	//ScanSeverity is the lowest vulnerability severity that fails the pipeline (default HIGH)
+	ScanSeverity string `json:"scanSeverity,omitempty"`
+}
+
+//Backend represents an environment's terraform (s3) backend configuration
+type Backend struct {
+	Bucket string `json:"bucket,omitempty"`
+	Key    string `json:"key,omitempty"`
+	Region string `json:"region,omitempty"`
+}
+
+//the providers that are built in
+var builtInProviders = []string{
+	"local",
+	"circleciv2",
+	"githubactions",
+	"awscodebuild",
+	"gitlabci",
+	"bitbucket",
+	"jenkins",
+	"azurepipelines",
+	"buildkite",
+}
+
+//VersioningStrategies are the supported image versioning strategies
+var VersioningStrategies = []string{
+	VersioningStatic,
+	VersioningSemverFile,
+	VersioningPackageJSON,
+	VersioningGitTag,
+	VersioningCommitSHA,
+}
+
+//ValidateOptions returns an error if the build provider options are invalid
+func ValidateOptions(options Options) error {
+	if options.Versioning != "" && !containsString(VersioningStrategies, options.Versioning) {
+		return fmt.Errorf("versioning strategy not supported: %s (%s)", options.Versioning, strings.Join(VersioningStrategies, ", "))
+	}
+	if options.ScanSeverity != "" && !containsString(ScanSeverities, strings.ToUpper(options.ScanSeverity)) {
+		return fmt.Errorf("scan severity not supported: %s (%s)", options.ScanSeverity, strings.Join(ScanSeverities, ", "))
+	}
+	if options.Scan && len(options.Platforms) > 0 {
+		return errors.New("multi-arch images are pushed as they're built so they can't be scanned before they're pushed")
+	}
+	return nil
+}
+
 //Provider represents a build provider
 type Provider interface {
 	ProvideArtifacts(context Context) ([]*Artifact, error)
 }
 
+//TemplateExporter represents a build provider whose artifacts are rendered from templates
+//that can be exported and customized (see GetTemplateOverride)
+type TemplateExporter interface {
+	//ExportTemplates returns the provider's unrendered templates
+	ExportTemplates() []*Artifact
+}
+
+//PromotionProvider represents a build provider that can generate a single pipeline
+//that promotes the same image through multiple environments
+type PromotionProvider interface {
+	ProvidePromotionArtifacts(contexts []Context) ([]*Artifact, error)
+}
+
+//InfrastructureProvider represents a build provider that can generate pipelines that
+//plan the environments' terraform on pull requests and apply it on merge (with approval)
+type InfrastructureProvider interface {
+	ProvideInfrastructureArtifacts(contexts []Context) ([]*Artifact, error)
+}
+
 //GetProvider returns a build provider based on its name
 func GetProvider(provider string) (Provider, error) {
+	return GetProviderWithOptions(provider, Options{})
+}
+
+//GetProviderWithOptions returns a build provider based on its name that is configured with options
+func GetProviderWithOptions(provider string, options Options) (Provider, error) {
 	providerString := strings.ToLower(provider)
 
+	if options.OIDC && containsString(builtInProviders, providerString) && providerString != "githubactions" {
+		return nil, errors.New("OIDC is not supported by build provider: " + provider)
+	}
+	if (options.Deploy || len(options.Platforms) > 0) && containsString(builtInProviders, providerString) && providerString != "local" {
+		return nil, errors.New("deploy and platforms are only supported by build provider: local")
+	}
+	if options.IaC && containsString(builtInProviders, providerString) && providerString != "githubactions" && providerString != "gitlabci" {
+		return nil, errors.New("iac is not supported by build provider: " + provider)
+	}
+	if options.Versioning == VersioningGitTag && providerString == "jenkins" {
+		return nil, errors.New("versioning " + VersioningGitTag + " is not supported by build provider: jenkins (its checkout doesn't fetch tags)")
+	}
+	if err := ValidateOptions(options); err != nil {
+		return nil, err
+	}
+
 	if providerString == "local" {
-		return Local{}, nil
+		return Local{Options: options}, nil
 	}
 
 	if providerString == "circleciv2" {
-		return CircleCIv2{}, nil
+		return CircleCIv2{Options: options}, nil
 	}
 
 	if providerString == "githubactions" {
-		return GithubActions{}, nil
+		return GithubActions{Options: options}, nil
 	}
 
 	if providerString == "awscodebuild" {
-		return AWSCodeBuild{}, nil
+		return AWSCodeBuild{Options: options}, nil
+	}
+
+	if providerString == "gitlabci" {
+		return GitlabCI{Options: options}, nil
+	}
+
+	if providerString == "bitbucket" {
+		return Bitbucket{Options: options}, nil
+	}
+
+	if providerString == "jenkins" {
+		return Jenkins{Options: options}, nil
+	}
+
+	if providerString == "azurepipelines" {
+		return AzurePipelines{Options: options}, nil
+	}
+
+	if providerString == "buildkite" {
+		return Buildkite{Options: options}, nil
+	}
+
+	//look for an external plugin
+	if plugin := findPlugin(providerString, options); plugin != nil {
+		return plugin, nil
 	}
 
 	return nil, errors.New("build provider not supported: " + provider)
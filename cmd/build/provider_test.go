@@ -0,0 +1,300 @@
+package build
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestProviderOptions(t *testing.T) {
+	ctx := mockContext{
+		App:     "my-app",
+		Env:     "dev",
+		Account: "123456789",
+		Region:  "us-east-1",
+	}
+	options := Options{
+		Branches:      []string{"main", "release"},
+		Versioning:    VersioningSemverFile,
+		VersionFile:   "app/VERSION",
+		Dockerfile:    "docker/Dockerfile",
+		DockerContext: "./app",
+		BuildArgs:     []string{"FOO=bar"},
+	}
+
+	//every built-in provider should consume the options
+	for _, name := range builtInProviders {
+		provider, err := GetProviderWithOptions(name, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		artifacts, err := provider.ProvideArtifacts(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents := ""
+		for _, artifact := range artifacts {
+			contents += artifact.FileContents
+		}
+
+		expected := []string{
+			"cat app/VERSION",
+			"docker build -f docker/Dockerfile --build-arg FOO=bar -t ",
+			" ./app",
+		}
+		for _, e := range expected {
+			if !strings.Contains(contents, e) {
+				t.Error(name, "expecting", e)
+			}
+		}
+		if strings.Contains(contents, "0.1.0") {
+			t.Error(name, "not expecting static version")
+		}
+
+		//local builds and codebuild (webhook filters) don't have branch triggers
+		if name != "local" && name != "awscodebuild" && !strings.Contains(contents, "release") {
+			t.Error(name, "expecting release branch")
+		}
+	}
+}
+
+func TestContextTemplateDefaults(t *testing.T) {
+	c := getContextTemplate(mockContext{App: "my-app", Env: "dev"}, Options{})
+	if c.Version != defaultVersion {
+		t.Errorf("expected: %s; actual: %s", defaultVersion, c.Version)
+	}
+	if c.BranchPattern() != defaultBranch {
+		t.Errorf("expected: %s; actual: %s", defaultBranch, c.BranchPattern())
+	}
+	expected := "docker build -t $IMAGE ."
+	if c.DockerBuildCommand("$IMAGE") != expected {
+		t.Errorf("expected: %s; actual: %s", expected, c.DockerBuildCommand("$IMAGE"))
+	}
+	if c.NeedsGitTags() {
+		t.Fail()
+	}
+}
+
+func TestContextTemplateVersioning(t *testing.T) {
+	tests := map[string]string{
+		VersioningStatic:      defaultVersion,
+		VersioningSemverFile:  "$(cat VERSION)",
+		VersioningPackageJSON: "$(jq -r .version < package.json)",
+		VersioningGitTag:      "$(git describe --tags --abbrev=0)",
+		VersioningCommitSHA:   "$(git rev-parse --short HEAD)",
+	}
+	for versioning, expected := range tests {
+		c := getContextTemplate(mockContext{}, Options{Versioning: versioning})
+		if c.Version != expected {
+			t.Errorf("expected: %s; actual: %s", expected, c.Version)
+		}
+	}
+	if !getContextTemplate(mockContext{}, Options{Versioning: VersioningGitTag}).NeedsGitTags() {
+		t.Error("expecting git-tag versioning to need tags")
+	}
+}
+
+func TestProviderGitTagVersioning(t *testing.T) {
+	ctx := mockContext{App: "my-app", Env: "dev", Account: "123456789", Region: "us-west-1"}
+
+	//shallow clones (and checkouts that don't fetch tags) need to fetch them
+	tests := map[string]string{
+		"githubactions":  "fetch-depth: 0",
+		"gitlabci":       `GIT_DEPTH: "0"`,
+		"bitbucket":      "clone:\n  depth: full",
+		"azurepipelines": "  - checkout: self\n    fetchDepth: 0\n    fetchTags: true",
+		"buildkite":      "- git fetch --tags --force\n",
+		"awscodebuild":   "- git fetch --tags --unshallow || git fetch --tags\n",
+		"circleciv2":     "- run: git fetch --tags --force\n",
+	}
+	for name, expected := range tests {
+		for _, versioning := range []string{VersioningGitTag, ""} {
+			provider, err := GetProviderWithOptions(name, Options{Versioning: versioning})
+			if err != nil {
+				t.Fatal(err)
+			}
+			artifacts, err := provider.ProvideArtifacts(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			yaml := artifacts[0].FileContents
+			if strings.Contains(yaml, expected) != (versioning == VersioningGitTag) {
+				t.Errorf("%s (versioning %s) expected: %s; actual: %s", name, versioning, expected, yaml)
+			}
+			err = ValidateArtifact(artifacts[0])
+			if err != nil {
+				t.Error(err)
+			}
+		}
+	}
+
+	_, err := GetProviderWithOptions("jenkins", Options{Versioning: VersioningGitTag})
+	if err == nil {
+		t.Error("expecting error")
+	}
+}
+
+func TestProviderOptionsInvalidVersioning(t *testing.T) {
+	_, err := GetProviderWithOptions("gitlabci", Options{Versioning: "calver"})
+	if err == nil {
+		t.Error("expecting error")
+	}
+}
+
+func TestProviderScan(t *testing.T) {
+	ctx := mockContext{
+		App:     "my-app",
+		Env:     "dev",
+		Account: "123456789",
+		Region:  "us-east-1",
+	}
+	options := Options{Scan: true, ScanSeverity: "medium"}
+
+	//every built-in provider should scan images before they're pushed
+	for _, name := range builtInProviders {
+		provider, err := GetProviderWithOptions(name, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		results := [][]*Artifact{}
+		artifacts, err := provider.ProvideArtifacts(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, artifacts)
+		if promotionProvider, ok := provider.(PromotionProvider); ok {
+			artifacts, err := promotionProvider.ProvidePromotionArtifacts(mockPromotionContexts())
+			if err != nil {
+				t.Fatal(err)
+			}
+			results = append(results, artifacts)
+		}
+
+		for _, artifacts := range results {
+			contents := artifacts[0].FileContents
+			expected := []string{
+				"aquasec/trivy",
+				"--exit-code 1 --severity MEDIUM,HIGH,CRITICAL",
+				"anchore/syft",
+				SBOMFile,
+			}
+			for _, e := range expected {
+				if !strings.Contains(contents, e) {
+					t.Error(name, "expecting", e)
+				}
+			}
+			if strings.Index(contents, "aquasec/trivy") > strings.Index(contents, "docker push") {
+				t.Error(name, "expecting scan before push")
+			}
+		}
+	}
+}
+
+func TestProviderScanDisabled(t *testing.T) {
+	for _, name := range builtInProviders {
+		provider, err := GetProvider(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		artifacts, err := provider.ProvideArtifacts(mockContext{App: "my-app", Env: "dev"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(artifacts[0].FileContents, "trivy") {
+			t.Error(name, "not expecting scan")
+		}
+	}
+}
+
+func TestProviderScanInvalid(t *testing.T) {
+	_, err := GetProviderWithOptions("gitlabci", Options{Scan: true, ScanSeverity: "severe"})
+	if err == nil {
+		t.Error("expecting error")
+	}
+	_, err = GetProviderWithOptions("local", Options{Scan: true, Platforms: []string{"linux/arm64"}})
+	if err == nil {
+		t.Error("expecting error")
+	}
+}
+
+func TestProviderArtifactsValid(t *testing.T) {
+	ctx := mockContext{
+		App:     "my-app",
+		Env:     "dev",
+		Account: "123456789",
+		Region:  "us-east-1",
+	}
+	backends := map[string]Backend{
+		"dev": {Bucket: "tf-state", Key: "dev.terraform.tfstate", Region: "us-east-1"},
+	}
+	tests := []Options{
+		{},
+		{Scan: true, Versioning: VersioningGitTag, Branches: []string{"main", "release"}},
+		{IaC: true, Backends: backends},
+		{OIDC: true, IaC: true, Backends: backends},
+	}
+
+	//every built-in provider's artifacts should match their schemas
+	for _, options := range tests {
+		for _, name := range builtInProviders {
+			provider, err := GetProviderWithOptions(name, options)
+			if err != nil {
+				//unsupported options
+				continue
+			}
+			artifacts, err := provider.ProvideArtifacts(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if promotionProvider, ok := provider.(PromotionProvider); ok {
+				promotionArtifacts, err := promotionProvider.ProvidePromotionArtifacts(mockPromotionContexts())
+				if err != nil {
+					t.Fatal(err)
+				}
+				artifacts = append(artifacts, promotionArtifacts...)
+			}
+			if infrastructureProvider, ok := provider.(InfrastructureProvider); ok && options.IaC {
+				infrastructureArtifacts, err := infrastructureProvider.ProvideInfrastructureArtifacts([]Context{ctx})
+				if err != nil {
+					t.Fatal(err)
+				}
+				artifacts = append(artifacts, infrastructureArtifacts...)
+			}
+			err = ValidateArtifacts(artifacts)
+			if err != nil {
+				t.Error(name, err)
+			}
+		}
+	}
+}
+
+func TestPromotionImageDigest(t *testing.T) {
+	for _, name := range builtInProviders {
+		provider, err := GetProvider(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		promotionProvider, ok := provider.(PromotionProvider)
+		if !ok {
+			continue
+		}
+		artifacts, err := promotionProvider.ProvidePromotionArtifacts(mockPromotionContexts())
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents := artifacts[0].FileContents
+
+		//push failures shouldn't be hidden by a pipe and a missing digest should fail the build
+		expected := []string{
+			"docker inspect --format '{{index .RepoDigests 0}}'",
+			"unable to get the digest of",
+		}
+		for _, e := range expected {
+			if !strings.Contains(contents, e) {
+				t.Error(name, "expecting", e)
+			}
+		}
+		if strings.Contains(contents, "IMAGE} |") || strings.Contains(contents, "$IMAGE |") {
+			t.Error(name, "not expecting docker push output to be piped")
+		}
+	}
+}
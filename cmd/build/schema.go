@@ -0,0 +1,282 @@
+package build
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+//schema is a (small) subset of JSON Schema, written in YAML, that's used to validate CI config
+type schema struct {
+	Type                 schemaTypes        `yaml:"type"`
+	Required             []string           `yaml:"required"`
+	Properties           map[string]*schema `yaml:"properties"`
+	PatternProperties    map[string]*schema `yaml:"patternProperties"`
+	AdditionalProperties *schemaOrBool      `yaml:"additionalProperties"`
+	Items                *schema            `yaml:"items"`
+	Enum                 []interface{}      `yaml:"enum"`
+	AnyOf                []*schema          `yaml:"anyOf"`
+}
+
+//schemaTypes is a type or a list of types
+type schemaTypes []string
+
+//UnmarshalYAML allows a type to be a string or a list
+func (t *schemaTypes) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err == nil {
+		*t = schemaTypes{s}
+		return nil
+	}
+	var list []string
+	if err := unmarshal(&list); err != nil {
+		return err
+	}
+	*t = schemaTypes(list)
+	return nil
+}
+
+//schemaOrBool is a boolean (allowed or not) or a schema
+type schemaOrBool struct {
+	Allowed bool
+	Schema  *schema
+}
+
+//UnmarshalYAML allows additionalProperties to be a boolean or a schema
+func (s *schemaOrBool) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if err := unmarshal(&s.Allowed); err == nil {
+		return nil
+	}
+	s.Allowed = true
+	return unmarshal(&s.Schema)
+}
+
+//artifactSchema is the schema for the artifacts (matching a path pattern) of a provider
+type artifactSchema struct {
+	Provider string
+	Pattern  string
+	Schema   string
+}
+
+//artifactSchemas are the schemas for the YAML artifacts of the built-in providers
+var artifactSchemas = []artifactSchema{
+	{"awscodebuild", "buildspec.yml", awsBuildspecSchema},
+	{"azurepipelines", "azure-pipelines.yml", azurePipelinesSchema},
+	{"bitbucket", "bitbucket-pipelines.yml", bitbucketPipelinesSchema},
+	{"buildkite", ".buildkite/pipeline.yml", buildkiteSchema},
+	{"circleciv2", ".circleci/config.yml", circleCIv2Schema},
+	{"githubactions", ".github/workflows/*.yml", githubActionsSchema},
+	{"gitlabci", ".gitlab-ci.yml", gitlabCISchema},
+	{"gitlabci", ".gitlab/*.yml", gitlabCISchema},
+}
+
+//ValidationPatterns returns the path patterns of the files that can be validated for a provider
+//(or all providers if empty)
+func ValidationPatterns(provider string) ([]string, error) {
+	result := []string{}
+	for _, s := range artifactSchemas {
+		if provider == "" || s.Provider == provider {
+			result = append(result, s.Pattern)
+		}
+	}
+	if len(result) == 0 {
+		return nil, errors.New("validation is not supported for build provider: " + provider)
+	}
+	return result, nil
+}
+
+//ValidateArtifacts returns an error if any of the artifacts are invalid
+func ValidateArtifacts(artifacts []*Artifact) error {
+	for _, artifact := range artifacts {
+		if err := ValidateArtifact(artifact); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//ValidateArtifact returns an error if a YAML artifact can't be parsed or doesn't match
+//its provider's schema. Other artifacts (e.g., Jenkinsfile) are not validated.
+func ValidateArtifact(artifact *Artifact) error {
+	schemaYAML := getArtifactSchema(artifact.FilePath)
+	if schemaYAML == "" && !artifact.Merge {
+		return nil
+	}
+	doc, err := parseYAML(artifact.FileContents)
+	if err != nil {
+		return fmt.Errorf("%s: %v", artifact.FilePath, err)
+	}
+	if schemaYAML == "" {
+		return nil
+	}
+	s := schema{}
+	err = yaml.Unmarshal([]byte(schemaYAML), &s)
+	if err != nil {
+		return err
+	}
+	problems := s.validate("", doc)
+	if len(problems) > 0 {
+		return fmt.Errorf("%s:\n  %s", artifact.FilePath, strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+//returns the schema for a file path
+func getArtifactSchema(filePath string) string {
+	for _, s := range artifactSchemas {
+		if matched, _ := path.Match(s.Pattern, filePath); matched {
+			return s.Schema
+		}
+	}
+	return ""
+}
+
+//yaml 1.1 booleans that CI systems (yaml 1.2) treat as strings when used as keys, e.g., on:
+var boolKeys = regexp.MustCompile(`(?m)^(\s*(?:- )?)(y|Y|yes|Yes|YES|n|N|no|No|NO|on|On|ON|off|Off|OFF):`)
+
+//parses a YAML document, keeping keys like "on" as strings
+func parseYAML(contents string) (yaml.MapSlice, error) {
+	doc := yaml.MapSlice{}
+	err := yaml.Unmarshal([]byte(boolKeys.ReplaceAllString(contents, `$1"$2":`)), &doc)
+	return doc, err
+}
+
+//returns the problems with a value
+func (s *schema) validate(at string, value interface{}) []string {
+	name := at
+	if name == "" {
+		name = "(root)"
+	}
+
+	if len(s.Type) > 0 && !s.Type.matches(value) {
+		return []string{fmt.Sprintf("%s: expected %s", name, strings.Join(s.Type, " or "))}
+	}
+
+	if len(s.Enum) > 0 {
+		found := false
+		for _, e := range s.Enum {
+			if fmt.Sprint(e) == fmt.Sprint(value) {
+				found = true
+			}
+		}
+		if !found {
+			return []string{fmt.Sprintf("%s: expected one of %v", name, s.Enum)}
+		}
+	}
+
+	if len(s.AnyOf) > 0 {
+		var first []string
+		for _, option := range s.AnyOf {
+			problems := option.validate(at, value)
+			if len(problems) == 0 {
+				first = nil
+				break
+			}
+			if first == nil {
+				first = problems
+			}
+		}
+		if first != nil {
+			return first
+		}
+	}
+
+	problems := []string{}
+	switch v := value.(type) {
+	case yaml.MapSlice:
+		keys := map[string]bool{}
+		for _, item := range v {
+			keys[fmt.Sprint(item.Key)] = true
+		}
+		for _, required := range s.Required {
+			if !keys[required] {
+				problems = append(problems, fmt.Sprintf("%s: missing %s", name, required))
+			}
+		}
+		for _, item := range v {
+			key := fmt.Sprint(item.Key)
+			property, allowed := s.getPropertySchema(key)
+			if !allowed {
+				problems = append(problems, fmt.Sprintf("%s: unexpected %s", name, key))
+				continue
+			}
+			problems = append(problems, property.validate(join(at, key), item.Value)...)
+		}
+	case []interface{}:
+		if s.Items != nil {
+			for i, item := range v {
+				problems = append(problems, s.Items.validate(fmt.Sprintf("%s[%d]", at, i), item)...)
+			}
+		}
+	}
+	return problems
+}
+
+//returns the schema of an object's property and whether it's allowed
+func (s *schema) getPropertySchema(key string) (*schema, bool) {
+	if p, ok := s.Properties[key]; ok {
+		return p, true
+	}
+	patterns := []string{}
+	for pattern := range s.PatternProperties {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	for _, pattern := range patterns {
+		if regexp.MustCompile(pattern).MatchString(key) {
+			return s.PatternProperties[pattern], true
+		}
+	}
+	if s.AdditionalProperties == nil {
+		return &schema{}, true
+	}
+	if s.AdditionalProperties.Schema != nil {
+		return s.AdditionalProperties.Schema, true
+	}
+	return &schema{}, s.AdditionalProperties.Allowed
+}
+
+func (t schemaTypes) matches(value interface{}) bool {
+	for _, typ := range t {
+		switch typ {
+		case "object":
+			if _, ok := value.(yaml.MapSlice); ok {
+				return true
+			}
+		case "array":
+			if _, ok := value.([]interface{}); ok {
+				return true
+			}
+		case "string":
+			if _, ok := value.(string); ok {
+				return true
+			}
+		case "number":
+			switch value.(type) {
+			case int, int64, uint64, float64:
+				return true
+			}
+		case "boolean":
+			if _, ok := value.(bool); ok {
+				return true
+			}
+		case "null":
+			if value == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func join(parent string, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
@@ -0,0 +1,124 @@
+package build
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateArtifact(t *testing.T) {
+	artifact := createYAMLArtifact("buildspec.yml", `version: 0.2
+phases:
+  build:
+    commands:
+      - docker build .
+`)
+	err := ValidateArtifact(artifact)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestValidateArtifactIndentedBuildspec(t *testing.T) {
+	//phases indented under version
+	artifact := createYAMLArtifact("buildspec.yml", `version: 0.2
+  phases:
+    build:
+      commands:
+        - docker build .
+`)
+	err := ValidateArtifact(artifact)
+	if err == nil {
+		t.Error("expecting error")
+	}
+}
+
+func TestValidateArtifactSchema(t *testing.T) {
+	artifact := createYAMLArtifact("buildspec.yml", `version: 0.2
+phases:
+  deploy:
+    commands:
+      - docker build .
+  build:
+    commands: docker build .
+`)
+	err := ValidateArtifact(artifact)
+	if err == nil {
+		t.Fatal("expecting error")
+	}
+	t.Log(err)
+	expected := []string{
+		"phases: unexpected deploy",
+		"phases.build.commands: expected array",
+	}
+	for _, e := range expected {
+		if !strings.Contains(err.Error(), e) {
+			t.Error("expecting", e)
+		}
+	}
+}
+
+func TestValidateArtifactRequired(t *testing.T) {
+	artifact := createYAMLArtifact(".gitlab-ci.yml", `stages:
+  - build
+build:
+  stage: build
+.template:
+  image: alpine
+`)
+	err := ValidateArtifact(artifact)
+	if err == nil {
+		t.Fatal("expecting error")
+	}
+	expected := "build: missing script"
+	if !strings.Contains(err.Error(), expected) {
+		t.Errorf("expected: %s; actual: %s", expected, err.Error())
+	}
+	if strings.Contains(err.Error(), ".template") {
+		t.Error("not expecting hidden job to be validated")
+	}
+}
+
+func TestValidateArtifactOnKey(t *testing.T) {
+	//on: is parsed as a boolean by yaml 1.1
+	artifact := createYAMLArtifact(".github/workflows/dev.yml", `on:
+  push:
+    branches: [develop]
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    steps:
+      - run: docker build .
+`)
+	err := ValidateArtifact(artifact)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestValidateArtifactNotYAML(t *testing.T) {
+	err := ValidateArtifact(createArtifact("Jenkinsfile", "pipeline {\n  agent any: {\n}"))
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestValidationPatterns(t *testing.T) {
+	patterns, err := ValidationPatterns("gitlabci")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patterns) != 2 {
+		t.Error("expecting 2 patterns")
+	}
+	all, err := ValidationPatterns("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != len(artifactSchemas) {
+		t.Errorf("expected: %d; actual: %d", len(artifactSchemas), len(all))
+	}
+	_, err = ValidationPatterns("jenkins")
+	if err == nil {
+		t.Error("expecting error")
+	}
+}
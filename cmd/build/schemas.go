@@ -0,0 +1,352 @@
+package build
+
+//the schemas below cover the structure that the providers generate (and that's commonly
+//hand-written) rather than everything each CI system supports, so they're strict about
+//the top level and the shape of jobs/steps and lenient about the rest
+
+//awsBuildspecSchema is the schema for buildspec.yml
+const awsBuildspecSchema = `
+type: object
+required: [version, phases]
+additionalProperties: false
+properties:
+  version:
+    type: [number, string]
+  run-as:
+    type: string
+  env:
+    type: object
+  proxy:
+    type: object
+  batch:
+    type: object
+  reports:
+    type: object
+  cache:
+    type: object
+  secondary-artifacts:
+    type: object
+  artifacts:
+    type: object
+    properties:
+      files:
+        type: [array, string]
+        items:
+          type: string
+  phases:
+    type: object
+    additionalProperties: false
+    properties:
+      install: &phase
+        type: object
+        additionalProperties: false
+        properties:
+          run-as:
+            type: string
+          on-failure:
+            type: string
+          runtime-versions:
+            type: object
+          commands:
+            type: array
+            items:
+              type: string
+          finally:
+            type: array
+            items:
+              type: string
+      pre_build: *phase
+      build: *phase
+      post_build: *phase
+`
+
+//azurePipelinesSchema is the schema for azure-pipelines.yml
+const azurePipelinesSchema = `
+type: object
+anyOf:
+  - required: [steps]
+  - required: [jobs]
+  - required: [stages]
+  - required: [extends]
+properties:
+  trigger:
+    type: [string, array, object]
+  pr:
+    type: [string, array, object]
+  pool:
+    type: [string, object]
+  variables:
+    type: [object, array]
+  steps: &steps
+    type: array
+    items:
+      type: object
+      anyOf:
+        - required: [script]
+        - required: [bash]
+        - required: [pwsh]
+        - required: [powershell]
+        - required: [task]
+        - required: [checkout]
+        - required: [download]
+        - required: [publish]
+        - required: [template]
+      properties:
+        displayName:
+          type: string
+        env:
+          type: object
+  jobs:
+    type: array
+    items:
+      type: object
+      properties:
+        steps: *steps
+  stages:
+    type: array
+    items:
+      type: object
+`
+
+//bitbucketPipelinesSchema is the schema for bitbucket-pipelines.yml
+const bitbucketPipelinesSchema = `
+type: object
+required: [pipelines]
+additionalProperties: false
+properties:
+  image:
+    type: [string, object]
+  clone:
+    type: object
+  options:
+    type: object
+  definitions:
+    type: object
+  pipelines:
+    type: object
+    additionalProperties: false
+    properties:
+      default: &pipeline
+        type: array
+        items:
+          type: object
+          anyOf:
+            - required: [step]
+            - required: [parallel]
+            - required: [stage]
+      branches: &pipelines
+        type: object
+        additionalProperties: *pipeline
+      tags: *pipelines
+      bookmarks: *pipelines
+      pull-requests: *pipelines
+      custom: *pipelines
+`
+
+//buildkiteSchema is the schema for .buildkite/pipeline.yml
+const buildkiteSchema = `
+type: object
+required: [steps]
+properties:
+  env:
+    type: object
+  agents:
+    type: [object, array]
+  steps:
+    type: array
+    items:
+      type: [string, object]
+      anyOf:
+        - type: string
+          enum: [wait, block, input]
+        - type: object
+          properties:
+            label:
+              type: string
+            branches:
+              type: [string, array]
+            commands:
+              type: [string, array]
+              items:
+                type: string
+            command:
+              type: [string, array]
+              items:
+                type: string
+`
+
+//circleCIv2Schema is the schema for .circleci/config.yml
+const circleCIv2Schema = `
+type: object
+required: [version, jobs]
+additionalProperties: false
+properties:
+  version:
+    type: [number, string]
+  setup:
+    type: boolean
+  orbs:
+    type: object
+  commands:
+    type: object
+  executors:
+    type: object
+  parameters:
+    type: object
+  references:
+    type: object
+  aliases:
+    type: [object, array]
+  jobs:
+    type: object
+    additionalProperties:
+      type: object
+      required: [steps]
+      properties:
+        steps:
+          type: array
+          items:
+            type: [string, object]
+  workflows:
+    type: object
+    properties:
+      version:
+        type: [number, string]
+    additionalProperties:
+      type: object
+      required: [jobs]
+      properties:
+        jobs:
+          type: array
+          items:
+            type: [string, object]
+`
+
+//githubActionsSchema is the schema for .github/workflows/*.yml
+const githubActionsSchema = `
+type: object
+required: ["on", jobs]
+additionalProperties: false
+properties:
+  name:
+    type: string
+  run-name:
+    type: string
+  "on":
+    type: [string, array, object]
+  permissions:
+    type: [string, object]
+  env:
+    type: object
+  defaults:
+    type: object
+  concurrency:
+    type: [string, object]
+  jobs:
+    type: object
+    additionalProperties:
+      type: object
+      anyOf:
+        - required: [runs-on, steps]
+        - required: [uses]
+      properties:
+        runs-on:
+          type: [string, array, object]
+        needs:
+          type: [string, array]
+        environment:
+          type: [string, object]
+        steps:
+          type: array
+          items:
+            type: object
+            anyOf:
+              - required: [run]
+              - required: [uses]
+            additionalProperties: false
+            properties:
+              id:
+                type: string
+              if:
+                type: [string, boolean]
+              name:
+                type: string
+              uses:
+                type: string
+              run:
+                type: string
+              shell:
+                type: string
+              with:
+                type: object
+              env:
+                type: object
+              continue-on-error:
+                type: [boolean, string]
+              timeout-minutes:
+                type: [number, string]
+              working-directory:
+                type: string
+`
+
+//gitlabCISchema is the schema for .gitlab-ci.yml (and the files it includes)
+const gitlabCISchema = `
+type: object
+properties:
+  stages:
+    type: array
+    items:
+      type: string
+  variables:
+    type: object
+  include:
+    type: [string, array, object]
+  workflow:
+    type: object
+  default:
+    type: object
+  image:
+    type: [string, object]
+  services:
+    type: array
+  before_script:
+    type: [string, array]
+  after_script:
+    type: [string, array]
+  cache:
+    type: [object, array]
+patternProperties:
+  "^\\.":
+    type: object
+additionalProperties:
+  type: object
+  anyOf:
+    - required: [script]
+    - required: [trigger]
+    - required: [extends]
+  properties:
+    stage:
+      type: string
+    script:
+      type: [string, array]
+    extends:
+      type: [string, array]
+    needs:
+      type: array
+    rules:
+      type: array
+      items:
+        type: object
+    only:
+      type: [string, array, object]
+    except:
+      type: [string, array, object]
+    when:
+      enum: [on_success, on_failure, always, manual, delayed, never]
+    environment:
+      type: [string, object]
+    variables:
+      type: object
+    artifacts:
+      type: object
+`
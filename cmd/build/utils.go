@@ -28,3 +28,26 @@ func applyTemplate(textTemplate string, data interface{}) string {
 	w.Flush()
 	return buf.String()
 }
+
+//renderTemplate executes a user supplied template, returning any errors
+func renderTemplate(textTemplate string, data interface{}) (string, error) {
+	tmpl, err := template.New("t").Parse(textTemplate)
+	if err != nil {
+		return "", err
+	}
+	buf := new(bytes.Buffer)
+	err = tmpl.Execute(buf, data)
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func containsString(s []string, searchterm string) bool {
+	for _, a := range s {
+		if a == searchterm {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/turnerlabs/fargate-create/cmd/build"
+)
+
+func TestTemplateBuildProvider(t *testing.T) {
+
+	//arrange
+	teardownTestCase := setupTestCase(t)
+	defer teardownTestCase(t)
+	targetDir = tmpDir
+	defer func() { targetDir = targetInfrastructureDir }()
+	context = scaffoldContext{App: "my-app", Env: "dev", AccountID: "123456789", Region: "us-east-1"}
+
+	envTargetDir := filepath.Join(tmpDir, envDir, "dev")
+	providerDir := filepath.Join(envTargetDir, "ci", "airflow", ".airflow")
+	err := os.MkdirAll(providerDir, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(providerDir, "pipeline.yml"), []byte("cluster: {{ .App }}-{{ .Env }}"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//act/assert
+	//templates without a build section don't supply providers
+	if provider, _ := getTemplateBuildProvider("airflow", build.Options{}); provider != nil {
+		t.Error("not expecting a provider")
+	}
+
+	err = ioutil.WriteFile(filepath.Join(envTargetDir, templateConfigFile), []byte("build:\n  dir: ci\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider, err := getTemplateBuildProvider("airflow", build.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if provider == nil {
+		t.Fatal("expecting a provider")
+	}
+	artifacts, err := provider.ProvideArtifacts(context)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(artifacts) != 1 {
+		t.Fatalf("expected: %d; actual: %d", 1, len(artifacts))
+	}
+	if artifacts[0].FilePath != ".airflow/pipeline.yml" {
+		t.Errorf("expected: %s; actual: %s", ".airflow/pipeline.yml", artifacts[0].FilePath)
+	}
+	if artifacts[0].FileContents != "cluster: my-app-dev" {
+		t.Errorf("expected: %s; actual: %s", "cluster: my-app-dev", artifacts[0].FileContents)
+	}
+
+	//providers not supplied by the template use the built-in ones
+	if provider, _ := getTemplateBuildProvider("circleciv2", build.Options{}); provider != nil {
+		t.Error("not expecting a provider")
+	}
+
+	//the template's provider would silently ignore the role
+	if _, err := getTemplateBuildProvider("airflow", build.Options{OIDC: true}); err == nil {
+		t.Error("expecting error")
+	}
+
+	//the template's provider renders a single environment
+	buildEnvs = []string{"dev", "prod"}
+	defer func() { buildEnvs = nil }()
+	if provider, err := getTemplateBuildProvider("airflow", build.Options{}); provider != nil || err != nil {
+		t.Error("not expecting a provider", err)
+	}
+}
+
+func TestBuildBackends(t *testing.T) {
+
+	//arrange
+	teardownTestCase := setupTestCase(t)
+	defer teardownTestCase(t)
+	targetDir = tmpDir
+	defer func() { targetDir = targetInfrastructureDir }()
+	context = scaffoldContext{App: "my-app", Env: "dev", AccountID: "123456789", Region: "us-east-1"}
+
+	envTargetDir := filepath.Join(tmpDir, envDir, "dev")
+	err := os.MkdirAll(envTargetDir, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	maintf := updateTerraformBackend(`terraform {
+  backend "s3" {
+    region  = "us-east-1"
+    profile = ""
+    bucket  = ""
+    key     = "dev.terraform.tfstate"
+  }
+}
+`, "default", "my-app", "dev", "us-west-2")
+	err = ioutil.WriteFile(filepath.Join(envTargetDir, "main.tf"), []byte(maintf), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//act
+	backends := getBuildBackends(nil)
+
+	//assert
+	expected := build.Backend{Bucket: "tf-state-my-app", Key: "dev.terraform.tfstate", Region: "us-west-2"}
+	if backends["dev"] != expected {
+		t.Errorf("expected: %v; actual: %v", expected, backends["dev"])
+	}
+}
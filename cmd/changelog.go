@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const changelogFile = "CHANGELOG.md"
+
+//matches semantic versions like 0.4.3, v1.0 or 1.2.0-beta.1
+var semverRegex = regexp.MustCompile(`^v?\d+(\.\d+){0,2}([-+][\w.-]*)?$`)
+
+//matches changelog headings like "## 0.9.0 (2020-07-06)", "## v1.0.0" or "## [1.2.0] - 2021-01-01"
+var changelogHeadingRegex = regexp.MustCompile(`^##\s+\[?v?(\d+(\.\d+){0,2}[-+\w.]*)\]?`)
+
+//changelogEntry represents the release notes for a single version
+type changelogEntry struct {
+	Version string
+	Notes   string
+}
+
+//parseChangelog returns the entries in a changelog in the order they appear
+func parseChangelog(changelog string) []*changelogEntry {
+	result := []*changelogEntry{}
+	var current *changelogEntry
+	for _, line := range strings.Split(changelog, "\n") {
+		if match := changelogHeadingRegex.FindStringSubmatch(line); match != nil {
+			current = &changelogEntry{Version: match[1]}
+			result = append(result, current)
+		}
+		if current != nil {
+			current.Notes += line + "\n"
+		}
+	}
+	for _, entry := range result {
+		entry.Notes = strings.TrimSpace(entry.Notes)
+	}
+	return result
+}
+
+//getChangelogEntries returns the entries after the installed version up to and including the target version
+//(if the installed version is unknown, all entries up to the target version are returned)
+func getChangelogEntries(entries []*changelogEntry, installed string, target string) []*changelogEntry {
+	result := []*changelogEntry{}
+	for _, entry := range entries {
+		if target != "" && compareVersions(entry.Version, target) > 0 {
+			continue
+		}
+		if installed != "" && compareVersions(entry.Version, installed) <= 0 {
+			continue
+		}
+		result = append(result, entry)
+	}
+	return result
+}
+
+//loads a template's changelog (if it has one)
+func loadChangelog(templateDir string) []*changelogEntry {
+	dat, err := ioutil.ReadFile(filepath.Join(templateDir, changelogFile))
+	if os.IsNotExist(err) {
+		debug("no changelog found in template")
+		return nil
+	}
+	check(err)
+	return parseChangelog(string(dat))
+}
+
+//getTemplateVersion returns the version of a downloaded template, using the
+//ref it was downloaded with or the latest version in its changelog
+func getTemplateVersion(templateDir string, templateURL string) string {
+	ref := getTemplateRef(templateURL)
+	if ref != "" && isSemver(ref) {
+		return ref
+	}
+	version := ""
+	entries := loadChangelog(templateDir)
+	if len(entries) > 0 {
+		version = entries[0].Version
+	}
+
+	//branches and commits can't be compared with other versions
+	if ref != "" {
+		if version != "" {
+			fmt.Fprintf(os.Stderr, "WARNING: template ref %s isn't a version, using the latest version in its changelog (%s)\n", ref, version)
+		} else {
+			fmt.Fprintf(os.Stderr, "WARNING: template ref %s isn't a version, changelogs and major version upgrades can't be detected\n", ref)
+		}
+	}
+	return version
+}
+
+//isSemver returns true if a version (or ref) is a semantic version
+func isSemver(version string) bool {
+	return semverRegex.MatchString(strings.TrimSpace(version))
+}
+
+//getTemplateRef returns the ref (e.g., ?ref=v0.4.3) of a template url
+func getTemplateRef(templateURL string) string {
+	parts := strings.SplitN(templateURL, "?", 2)
+	if len(parts) < 2 {
+		return ""
+	}
+	query, err := url.ParseQuery(parts[1])
+	if err != nil {
+		return ""
+	}
+	return query.Get("ref")
+}
+
+//setTemplateRef returns a template url with its ref set to the specified value
+//(an empty ref removes it)
+func setTemplateRef(templateURL string, ref string) string {
+	parts := strings.SplitN(templateURL, "?", 2)
+	query := url.Values{}
+	if len(parts) == 2 {
+		q, err := url.ParseQuery(parts[1])
+		check(err)
+		query = q
+	}
+	if ref == "" {
+		query.Del("ref")
+	} else {
+		query.Set("ref", ref)
+	}
+	if len(query) == 0 {
+		return parts[0]
+	}
+	return parts[0] + "?" + query.Encode()
+}
+
+//compareVersions compares two semantic versions (e.g., v0.4.3 and 0.5.0)
+//returning -1, 0, or 1. pre-release and build suffixes are ignored
+func compareVersions(a string, b string) int {
+	va := parseVersion(a)
+	vb := parseVersion(b)
+	for i := range va {
+		if va[i] < vb[i] {
+			return -1
+		}
+		if va[i] > vb[i] {
+			return 1
+		}
+	}
+	return 0
+}
+
+//returns the major version of a semantic version
+func majorVersion(version string) int {
+	return parseVersion(version)[0]
+}
+
+func parseVersion(version string) [3]int {
+	result := [3]int{}
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		version = version[:i]
+	}
+	for i, part := range strings.SplitN(version, ".", 3) {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			break
+		}
+		result[i] = n
+	}
+	return result
+}
@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testChangelog = `# Changelog
+
+## 1.0.0 (2020-01-01)
+
+- breaking: requires terraform 0.12
+
+## v0.5.0
+
+- adds autoscaling
+
+## [0.4.3] - 2019-06-01
+
+- fixes a bug
+
+## 0.4.2
+
+- initial
+`
+
+func TestParseChangelog(t *testing.T) {
+
+	entries := parseChangelog(testChangelog)
+	for _, e := range entries {
+		t.Log(e.Version)
+	}
+
+	expected := []string{"1.0.0", "0.5.0", "0.4.3", "0.4.2"}
+	if len(entries) != len(expected) {
+		t.Fatalf("expected: %v entries; actual: %v", len(expected), len(entries))
+	}
+	for i, v := range expected {
+		if entries[i].Version != v {
+			t.Errorf("expected: %s; actual: %s", v, entries[i].Version)
+		}
+	}
+	if entries[1].Notes != "## v0.5.0\n\n- adds autoscaling" {
+		t.Errorf("not expecting: %s", entries[1].Notes)
+	}
+}
+
+func TestGetChangelogEntries(t *testing.T) {
+
+	entries := getChangelogEntries(parseChangelog(testChangelog), "v0.4.2", "v0.5.0")
+	if len(entries) != 2 {
+		t.Fatalf("expected: 2 entries; actual: %v", len(entries))
+	}
+	if entries[0].Version != "0.5.0" || entries[1].Version != "0.4.3" {
+		t.Errorf("not expecting: %s, %s", entries[0].Version, entries[1].Version)
+	}
+
+	//unknown installed version
+	entries = getChangelogEntries(parseChangelog(testChangelog), "", "0.4.3")
+	if len(entries) != 2 {
+		t.Errorf("expected: 2 entries; actual: %v", len(entries))
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+
+	tests := []struct {
+		a        string
+		b        string
+		expected int
+	}{
+		{"0.4.3", "v0.5.0", -1},
+		{"v1.0.0", "0.9.9", 1},
+		{"v0.5", "0.5.0", 0},
+		{"0.10.0", "0.9.0", 1},
+		{"1.0.0-beta", "1.0.0", 0},
+	}
+	for _, test := range tests {
+		if actual := compareVersions(test.a, test.b); actual != test.expected {
+			t.Errorf("%s vs %s expected: %v; actual: %v", test.a, test.b, test.expected, actual)
+		}
+	}
+}
+
+func TestSetTemplateRef(t *testing.T) {
+
+	tests := []struct {
+		url      string
+		ref      string
+		expected string
+	}{
+		{"git@github.com:turnerlabs/terraform-ecs-fargate", "v0.5.0", "git@github.com:turnerlabs/terraform-ecs-fargate?ref=v0.5.0"},
+		{"git@github.com:turnerlabs/terraform-ecs-fargate?ref=v0.4.3", "v0.5.0", "git@github.com:turnerlabs/terraform-ecs-fargate?ref=v0.5.0"},
+		{"git@github.com:turnerlabs/terraform-ecs-fargate?ref=v0.4.3", "", "git@github.com:turnerlabs/terraform-ecs-fargate"},
+	}
+	for _, test := range tests {
+		if actual := setTemplateRef(test.url, test.ref); actual != test.expected {
+			t.Errorf("expected: %s; actual: %s", test.expected, actual)
+		}
+	}
+
+	if ref := getTemplateRef("git@github.com:turnerlabs/terraform-ecs-fargate?ref=v0.4.3"); ref != "v0.4.3" {
+		t.Errorf("expected: v0.4.3; actual: %s", ref)
+	}
+}
+
+func TestIsSemver(t *testing.T) {
+
+	tests := map[string]bool{
+		"0.4.3":        true,
+		"v1.0.0":       true,
+		"v0.5":         true,
+		"1.2.0-beta.1": true,
+		"master":       false,
+		"develop":      false,
+		"a1b2c3d":      false,
+		"":             false,
+	}
+	for version, expected := range tests {
+		if actual := isSemver(version); actual != expected {
+			t.Errorf("%s expected: %v; actual: %v", version, expected, actual)
+		}
+	}
+}
+
+func TestGetTemplateVersion_Branch(t *testing.T) {
+
+	//arrange
+	teardownTestCase := setupTestCase(t)
+	defer teardownTestCase(t)
+	err := ioutil.WriteFile(filepath.Join(tmpDir, changelogFile), []byte("## 0.6.0\n\n- foo\n\n## 0.5.0\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//act
+	//(the warning isn't written to stdout, which is used for the --check report)
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = w
+	version := getTemplateVersion(tmpDir, "git@github.com:turnerlabs/terraform-ecs-fargate?ref=master")
+	os.Stdout = stdout
+	w.Close()
+	output, _ := ioutil.ReadAll(r)
+
+	//assert
+	if version != "0.6.0" {
+		t.Errorf("expected: 0.6.0; actual: %s", version)
+	}
+	if len(output) > 0 {
+		t.Error("not expecting output", string(output))
+	}
+	if version := getTemplateVersion(tmpDir, "git@github.com:turnerlabs/terraform-ecs-fargate?ref=v0.5.0"); version != "v0.5.0" {
+		t.Errorf("expected: v0.5.0; actual: %s", version)
+	}
+}
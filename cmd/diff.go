@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+//the number of unchanged lines shown around changes
+const diffContext = 2
+
+//diffLine is a line in a diff, prefixed with " " (unchanged), "-" (removed), or "+" (added)
+type diffLine struct {
+	Op   string
+	Text string
+}
+
+//getDiff returns the line differences between two strings (longest common subsequence)
+func getDiff(before string, after string) []diffLine {
+	a := strings.Split(strings.TrimSuffix(before, "\n"), "\n")
+	b := strings.Split(strings.TrimSuffix(after, "\n"), "\n")
+
+	//lcs[i][j] is the length of the lcs of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	result := []diffLine{}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			result = append(result, diffLine{" ", a[i]})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			result = append(result, diffLine{"-", a[i]})
+			i++
+		default:
+			result = append(result, diffLine{"+", b[j]})
+			j++
+		}
+	}
+	return result
+}
+
+//printDiff writes the changed lines (and the lines around them) between two strings
+func printDiff(w io.Writer, before string, after string) {
+	lines := getDiff(before, after)
+
+	//show changes along with some context
+	show := make([]bool, len(lines))
+	for i, line := range lines {
+		if line.Op == " " {
+			continue
+		}
+		for j := i - diffContext; j <= i+diffContext; j++ {
+			if j >= 0 && j < len(lines) {
+				show[j] = true
+			}
+		}
+	}
+
+	skipped := false
+	for i, line := range lines {
+		if !show[i] {
+			skipped = true
+			continue
+		}
+		if skipped {
+			fmt.Fprintln(w, "...")
+			skipped = false
+		}
+		fmt.Fprintln(w, line.Op+line.Text)
+	}
+}
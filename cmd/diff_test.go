@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestGetDiff(t *testing.T) {
+	lines := getDiff("a\nb\nc\n", "a\nc\nd\n")
+	expected := []diffLine{{" ", "a"}, {"-", "b"}, {" ", "c"}, {"+", "d"}}
+	if len(lines) != len(expected) {
+		t.Fatal("expecting", expected, "actual", lines)
+	}
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Errorf("expected: %v; actual: %v", expected[i], lines[i])
+		}
+	}
+}
+
+func TestPrintDiff(t *testing.T) {
+	before := "1\n2\n3\n4\n5\n6\n7\n8\n"
+	after := "1\n2\n3\n4\n5\n6\n7\nx\n"
+	var buf bytes.Buffer
+	printDiff(&buf, before, after)
+	expected := "...\n 6\n 7\n-8\n+x\n"
+	if buf.String() != expected {
+		t.Errorf("expected: %s; actual: %s", expected, buf.String())
+	}
+}
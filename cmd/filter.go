@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+//files upgraded when a template doesn't specify any include patterns
+var defaultUpgradeIncludes = []string{"*.tf", "*.md", "*.tpl"}
+
+//files that are never upgraded since they belong to the user
+var defaultUpgradeExcludes = []string{".git", ".terraform", "terraform.tfvars", "terraform.tfvars.json"}
+
+//upgradeFilter determines which template files are upgraded
+//
+//patterns without a "/" are matched against file names at any depth
+//(e.g., "*.tf"), otherwise they are matched against the path relative
+//to the module (e.g., "modules/*/*.tf"). a pattern that matches a
+//directory applies to everything underneath it
+type upgradeFilter struct {
+	Include []string
+	Exclude []string
+}
+
+func getUpgradeFilter(config *templateConfig) *upgradeFilter {
+	filter := upgradeFilter{
+		Include: defaultUpgradeIncludes,
+		Exclude: defaultUpgradeExcludes,
+	}
+	if config != nil && config.Upgrade != nil {
+		if len(config.Upgrade.Include) > 0 {
+			filter.Include = config.Upgrade.Include
+		}
+		filter.Exclude = append(filter.Exclude, config.Upgrade.Exclude...)
+	}
+	return &filter
+}
+
+//includes returns true if a file (relative to the module) should be upgraded
+func (filter *upgradeFilter) includes(file string) bool {
+	file = filepath.ToSlash(file)
+	return matchesAnyPattern(filter.Include, file) && !matchesAnyPattern(filter.Exclude, file)
+}
+
+//excludesDirectory returns true if an entire directory (relative to the module) is excluded
+func (filter *upgradeFilter) excludesDirectory(dir string) bool {
+	return matchesAnyPattern(filter.Exclude, filepath.ToSlash(dir))
+}
+
+func matchesAnyPattern(patterns []string, file string) bool {
+	for _, pattern := range patterns {
+		if matchesPattern(pattern, file) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesPattern(pattern string, file string) bool {
+	pattern = strings.TrimSuffix(filepath.ToSlash(pattern), "/")
+
+	//check the file and each of its parent directories
+	for p := file; p != "." && p != "/" && p != ""; p = path.Dir(p) {
+		name := p
+		if !strings.Contains(pattern, "/") {
+			name = path.Base(p)
+		}
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
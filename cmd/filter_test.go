@@ -0,0 +1,51 @@
+package cmd
+
+import "testing"
+
+func TestUpgradeFilter_Defaults(t *testing.T) {
+
+	filter := getUpgradeFilter(nil)
+
+	included := []string{"main.tf", "README.md", "policy.tpl", "modules/lambda/main.tf"}
+	for _, f := range included {
+		if !filter.includes(f) {
+			t.Errorf("expected %s to be included", f)
+		}
+	}
+
+	excluded := []string{"autoscale-time.zip", "terraform.tfvars", ".terraform/modules/foo.tf", "fargate-create.yml"}
+	for _, f := range excluded {
+		if filter.includes(f) {
+			t.Errorf("expected %s to be excluded", f)
+		}
+	}
+}
+
+func TestUpgradeFilter_Config(t *testing.T) {
+
+	config := templateConfig{
+		Upgrade: &upgradeConfig{
+			Include: []string{"*.tf", "*.json", "*.zip", "scripts/*.sh"},
+			Exclude: []string{"modules/legacy", "*.tfvars.json"},
+		},
+	}
+	filter := getUpgradeFilter(&config)
+
+	included := []string{"main.tf", "policy.json", "autoscale-time.zip", "scripts/deploy.sh", "modules/lambda/main.tf"}
+	for _, f := range included {
+		if !filter.includes(f) {
+			t.Errorf("expected %s to be included", f)
+		}
+	}
+
+	excluded := []string{"README.md", "deploy.sh", "modules/legacy/main.tf", "terraform.tfvars.json", "app.tfvars.json"}
+	for _, f := range excluded {
+		if filter.includes(f) {
+			t.Errorf("expected %s to be excluded", f)
+		}
+	}
+
+	if !filter.excludesDirectory("modules/legacy") {
+		t.Error("expected modules/legacy to be excluded")
+	}
+}
@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	stateDir     = ".fargate-create"
+	lockFileName = "lock.json"
+)
+
+//templateLock records the template that was installed along with
+//checksums of the files it installed, so that upgrades can tell
+//template files apart from user-authored files
+type templateLock struct {
+	Template string            `json:"template"`
+	Version  string            `json:"version,omitempty"`
+	Modules  map[string]string `json:"modules,omitempty"`
+	Files    map[string]string `json:"files"`
+
+	//optional files that the user declined, along with the question they declined
+	Declined map[string]string `json:"declined,omitempty"`
+}
+
+func getLockFile() string {
+	return filepath.Join(stateDir, lockFileName)
+}
+
+//loads the lock file, returning an empty lock if one hasn't been written yet
+func loadTemplateLock() *templateLock {
+	lock := templateLock{Files: map[string]string{}}
+	dat, err := ioutil.ReadFile(getLockFile())
+	if os.IsNotExist(err) {
+		debug("no lock file found")
+		return &lock
+	}
+	check(err)
+	err = json.Unmarshal(dat, &lock)
+	check(err)
+	if lock.Files == nil {
+		lock.Files = map[string]string{}
+	}
+	return &lock
+}
+
+//setModuleVersion records the template version installed in a module (base or env)
+//since modules can be upgraded independently
+func (lock *templateLock) setModuleVersion(module string, version string) {
+	if version == "" {
+		return
+	}
+	if lock.Modules == nil {
+		lock.Modules = map[string]string{}
+	}
+	lock.Modules[lockKey(module)] = version
+}
+
+//backfillModuleVersions records the global version for modules that don't have their own
+//(installed before module versions were recorded) so that they keep it when the global version moves
+func (lock *templateLock) backfillModuleVersions(modules []string) {
+	for _, m := range modules {
+		if _, ok := lock.Modules[lockKey(m)]; !ok {
+			lock.setModuleVersion(m, lock.Version)
+		}
+	}
+}
+
+//getInstalledVersion returns the oldest template version installed in the specified modules
+func (lock *templateLock) getInstalledVersion(modules []string) string {
+	result := ""
+	for _, m := range modules {
+		version, ok := lock.Modules[lockKey(m)]
+		if !ok {
+			version = lock.Version
+		}
+		if result == "" || compareVersions(version, result) < 0 {
+			result = version
+		}
+	}
+	if result == "" {
+		return lock.Version
+	}
+	return result
+}
+
+func (lock *templateLock) save() {
+	err := os.MkdirAll(stateDir, 0755)
+	check(err)
+	dat, err := json.MarshalIndent(lock, "", "  ")
+	check(err)
+	debug("writing", getLockFile())
+	err = ioutil.WriteFile(getLockFile(), append(dat, '\n'), 0644)
+	check(err)
+}
+
+func lockKey(file string) string {
+	return filepath.ToSlash(filepath.Clean(file))
+}
+
+//record stores the current checksum of a file installed by the template
+func (lock *templateLock) record(file string) {
+	lock.Files[lockKey(file)] = fileChecksum(file)
+	delete(lock.Declined, lockKey(file))
+}
+
+//decline records an optional file that the user didn't want so that it isn't offered again
+//(unless the question changes)
+func (lock *templateLock) decline(file string, question string) {
+	if lock.Declined == nil {
+		lock.Declined = map[string]string{}
+	}
+	lock.Declined[lockKey(file)] = question
+}
+
+//declined returns true if the user declined an optional file when asked the same question
+func (lock *templateLock) declined(file string, question string) bool {
+	q, ok := lock.Declined[lockKey(file)]
+	return ok && q == question
+}
+
+//recordDirectory records every file in a directory tree
+func (lock *templateLock) recordDirectory(dir string) {
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			lock.record(path)
+		}
+		return nil
+	})
+	check(err)
+}
+
+func (lock *templateLock) forget(file string) {
+	delete(lock.Files, lockKey(file))
+}
+
+//forgetDirectory removes all records for files in a directory tree
+func (lock *templateLock) forgetDirectory(dir string) {
+	prefix := lockKey(dir) + "/"
+	for key := range lock.Files {
+		if strings.HasPrefix(key, prefix) {
+			delete(lock.Files, key)
+		}
+	}
+}
+
+//recordUnknown stores a file installed by the template whose original checksum
+//isn't known (installed before checksums were recorded), so it's treated as modified
+func (lock *templateLock) recordUnknown(file string) {
+	lock.Files[lockKey(file)] = ""
+}
+
+//refreshDirectory updates the checksums of the recorded files in a directory tree that
+//was just installed (to pick up template customizations) and forgets the ones that have
+//been deleted. files in other directories keep their checksums so that local changes
+//to them are still detected.
+func (lock *templateLock) refreshDirectory(dir string) {
+	prefix := lockKey(dir) + "/"
+	for key := range lock.Files {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if _, err := os.Stat(filepath.FromSlash(key)); os.IsNotExist(err) {
+			delete(lock.Files, key)
+			continue
+		}
+		lock.record(filepath.FromSlash(key))
+	}
+}
+
+func (lock *templateLock) installed(file string) bool {
+	_, ok := lock.Files[lockKey(file)]
+	return ok
+}
+
+//modified returns true if a recorded file has changed since it was installed
+func (lock *templateLock) modified(file string) bool {
+	return lock.Files[lockKey(file)] != fileChecksum(file)
+}
+
+//filesIn returns the recorded files in a directory tree, relative to the directory
+func (lock *templateLock) filesIn(dir string) []string {
+	prefix := lockKey(dir) + "/"
+	result := []string{}
+	for key := range lock.Files {
+		if strings.HasPrefix(key, prefix) {
+			result = append(result, filepath.FromSlash(strings.TrimPrefix(key, prefix)))
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func fileChecksum(file string) string {
+	f, err := os.Open(file)
+	check(err)
+	defer f.Close()
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	check(err)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLockRefreshDirectory(t *testing.T) {
+
+	//arrange
+	teardownTestCase := setupTestCase(t)
+	defer teardownTestCase(t)
+	base := filepath.Join(tmpDir, "base", "main.tf")
+	env := filepath.Join(tmpDir, "env", "dev", "main.tf")
+	deleted := filepath.Join(tmpDir, "env", "dev", "deleted.tf")
+	for _, f := range []string{base, env, deleted} {
+		os.MkdirAll(filepath.Dir(f), 0755)
+		ioutil.WriteFile(f, []byte("template"), 0644)
+	}
+	lock := &templateLock{Files: map[string]string{}}
+	lock.record(base)
+	lock.record(env)
+	lock.record(deleted)
+
+	//act
+	ioutil.WriteFile(base, []byte("user change"), 0644)
+	ioutil.WriteFile(env, []byte("template customization"), 0644)
+	os.Remove(deleted)
+	lock.refreshDirectory(filepath.Join(tmpDir, "env", "dev"))
+
+	//assert
+	if !lock.modified(base) {
+		t.Error("expecting changes outside of the directory to be detected")
+	}
+	if lock.modified(env) {
+		t.Error("expecting customization to be recorded")
+	}
+	if lock.installed(deleted) {
+		t.Error("expecting deleted file to be forgotten")
+	}
+}
+
+func TestLockRecordUnknown(t *testing.T) {
+
+	//arrange
+	teardownTestCase := setupTestCase(t)
+	defer teardownTestCase(t)
+	file := filepath.Join(tmpDir, "main.tf")
+	ioutil.WriteFile(file, []byte("user change"), 0644)
+	lock := &templateLock{Files: map[string]string{}}
+
+	//act
+	lock.recordUnknown(file)
+
+	//assert
+	if !lock.installed(file) {
+		t.Error("expecting file to be installed")
+	}
+	if !lock.modified(file) {
+		t.Error("expecting file to be modified")
+	}
+}
+
+func TestLockBackfillModuleVersions(t *testing.T) {
+	lock := &templateLock{
+		Version: "0.5.0",
+		Modules: map[string]string{"iac/env/dev": "0.4.0"},
+		Files:   map[string]string{},
+	}
+
+	lock.backfillModuleVersions([]string{"iac/base", "iac/env/dev", "iac/env/prod"})
+
+	expected := map[string]string{
+		"iac/base":     "0.5.0",
+		"iac/env/dev":  "0.4.0",
+		"iac/env/prod": "0.5.0",
+	}
+	for module, version := range expected {
+		if lock.Modules[module] != version {
+			t.Errorf("%s expected: %s; actual: %s", module, version, lock.Modules[module])
+		}
+	}
+}
+
+func TestLockDecline(t *testing.T) {
+
+	//arrange
+	teardownTestCase := setupTestCase(t)
+	defer teardownTestCase(t)
+	file := filepath.Join(tmpDir, "lb-https.tf")
+	lock := &templateLock{Files: map[string]string{}}
+
+	//act
+	lock.decline(file, "Would you like an HTTPS listener?")
+
+	//assert
+	if !lock.declined(file, "Would you like an HTTPS listener?") {
+		t.Error("expecting file to be declined")
+	}
+	if lock.declined(file, "Would you like HTTPS?") {
+		t.Error("expecting a new question to be asked")
+	}
+
+	//installing the file clears it
+	ioutil.WriteFile(file, []byte("https"), 0644)
+	lock.record(file)
+	if lock.declined(file, "Would you like an HTTPS listener?") {
+		t.Error("not expecting installed file to be declined")
+	}
+}
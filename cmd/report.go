@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+const (
+	reportFormatJSON     = "json"
+	reportFormatMarkdown = "markdown"
+
+	//returned by upgrade --check when the installed template is out of date
+	exitCodeUpgradesAvailable = 2
+)
+
+//upgradeReport represents the differences between an installed template and its source
+type upgradeReport struct {
+	Template         string          `json:"template"`
+	InstalledVersion string          `json:"installedVersion,omitempty"`
+	Version          string          `json:"version,omitempty"`
+	Modules          []*moduleReport `json:"modules"`
+}
+
+//moduleReport represents the differences for a single installed module (base or env)
+type moduleReport struct {
+	Module  string   `json:"module"`
+	Added   []string `json:"added"`
+	Changed []string `json:"changed"`
+	Removed []string `json:"removed"`
+
+	//required variables declared by the template that are missing from the var file
+	NewVariables []string `json:"newVariables"`
+
+	//variables in the var file that are no longer declared by the template
+	RemovedVariables []string `json:"removedVariables"`
+
+	//files that match the template
+	Unchanged []string `json:"-"`
+
+	//template directory the module was compared against
+	srcDir string
+
+	//the module's var file
+	varFile      string
+	varFormat    string
+	newVariables []*variableDeclaration
+
+	//values of the new variables (by name)
+	variableValues map[string]string
+}
+
+func (m *moduleReport) upgradesAvailable() bool {
+	return len(m.Added) > 0 || len(m.Changed) > 0 || len(m.Removed) > 0 ||
+		len(m.NewVariables) > 0 || len(m.RemovedVariables) > 0
+}
+
+func (report *upgradeReport) upgradesAvailable() bool {
+	for _, m := range report.Modules {
+		if m.upgradesAvailable() {
+			return true
+		}
+	}
+	return false
+}
+
+func writeUpgradeReport(w io.Writer, report *upgradeReport, format string) {
+	if format == reportFormatMarkdown {
+		fmt.Fprint(w, getUpgradeReportMarkdown(report))
+		return
+	}
+	b, err := json.MarshalIndent(report, "", "  ")
+	check(err)
+	fmt.Fprintln(w, string(b))
+}
+
+func getUpgradeReportMarkdown(report *upgradeReport) string {
+	md := "# fargate-create upgrade check\n\n"
+	md += fmt.Sprintf("template: `%s`\n", report.Template)
+	if report.Version != "" {
+		md += fmt.Sprintf("\nversion: `%s` -> `%s`\n", report.InstalledVersion, report.Version)
+	}
+	if !report.upgradesAvailable() {
+		md += "\nall modules are up to date\n"
+		return md
+	}
+	for _, m := range report.Modules {
+		md += fmt.Sprintf("\n## %s\n\n", m.Module)
+		if !m.upgradesAvailable() {
+			md += "up to date\n"
+			continue
+		}
+		md += "| file | status |\n"
+		md += "| ---- | ------ |\n"
+		for _, f := range m.Added {
+			md += fmt.Sprintf("| %s | added |\n", f)
+		}
+		for _, f := range m.Changed {
+			md += fmt.Sprintf("| %s | changed |\n", f)
+		}
+		for _, f := range m.Removed {
+			md += fmt.Sprintf("| %s | removed |\n", f)
+		}
+		for _, v := range m.NewVariables {
+			md += fmt.Sprintf("| %s | new required variable (%s) |\n", filepath.Base(m.varFile), v)
+		}
+		for _, v := range m.RemovedVariables {
+			md += fmt.Sprintf("| %s | variable no longer declared (%s) |\n", filepath.Base(m.varFile), v)
+		}
+	}
+	return md
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func getTestUpgradeReport() *upgradeReport {
+	return &upgradeReport{
+		Template:         "git::https://github.com/turnerlabs/terraform-ecs-fargate?ref=v0.5.0",
+		InstalledVersion: "0.4.0",
+		Version:          "0.5.0",
+		Modules: []*moduleReport{
+			{
+				Module:           "iac/base",
+				Added:            []string{},
+				Changed:          []string{},
+				Removed:          []string{},
+				NewVariables:     []string{},
+				RemovedVariables: []string{},
+			},
+			{
+				Module:           "iac/env/dev",
+				Added:            []string{"cicd.tf"},
+				Changed:          []string{"main.tf"},
+				Removed:          []string{"old.tf"},
+				NewVariables:     []string{"vpc"},
+				RemovedVariables: []string{"subnets"},
+				varFile:          "iac/env/dev/terraform.tfvars",
+			},
+		},
+	}
+}
+
+func TestUpgradeReportJSON(t *testing.T) {
+	var buf bytes.Buffer
+	writeUpgradeReport(&buf, getTestUpgradeReport(), reportFormatJSON)
+	t.Log(buf.String())
+
+	actual := upgradeReport{}
+	err := json.Unmarshal(buf.Bytes(), &actual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual.Version != "0.5.0" || actual.InstalledVersion != "0.4.0" {
+		t.Errorf("expected: %s; actual: %s", "0.4.0 -> 0.5.0", actual.InstalledVersion+" -> "+actual.Version)
+	}
+	if len(actual.Modules) != 2 {
+		t.Fatalf("expected: %d; actual: %d", 2, len(actual.Modules))
+	}
+	dev := actual.Modules[1]
+	if dev.Added[0] != "cicd.tf" || dev.Changed[0] != "main.tf" || dev.Removed[0] != "old.tf" ||
+		dev.NewVariables[0] != "vpc" || dev.RemovedVariables[0] != "subnets" {
+		t.Error("not expecting", dev)
+	}
+	if !actual.upgradesAvailable() {
+		t.Error("expecting upgrades")
+	}
+}
+
+func TestUpgradeReportMarkdown(t *testing.T) {
+	md := getUpgradeReportMarkdown(getTestUpgradeReport())
+	t.Log(md)
+
+	expected := []string{
+		"version: `0.4.0` -> `0.5.0`",
+		"## iac/base\n\nup to date\n",
+		"| cicd.tf | added |",
+		"| main.tf | changed |",
+		"| old.tf | removed |",
+		"| terraform.tfvars | new required variable (vpc) |",
+		"| terraform.tfvars | variable no longer declared (subnets) |",
+	}
+	for _, e := range expected {
+		if !strings.Contains(md, e) {
+			t.Error("expecting", e)
+		}
+	}
+}
+
+func TestUpgradeReportUpToDate(t *testing.T) {
+	report := getTestUpgradeReport()
+	report.Modules = report.Modules[:1]
+	if report.upgradesAvailable() {
+		t.Error("not expecting upgrades")
+	}
+	md := getUpgradeReportMarkdown(report)
+	if !strings.Contains(md, "all modules are up to date") {
+		t.Error("expecting", "all modules are up to date")
+	}
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var rollbackID string
+var rollbackList bool
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Undo an upgrade by restoring a backup",
+	Run:   doRollback,
+	Example: `
+fargate-create rollback
+fargate-create rollback --list
+fargate-create rollback --id 20200706153000
+`,
+}
+
+func init() {
+	rollbackCmd.Flags().StringVar(&rollbackID, "id", "", "id of the backup to restore (defaults to the most recent)")
+	rollbackCmd.Flags().BoolVar(&rollbackList, "list", false, "list available backups")
+	rootCmd.AddCommand(rollbackCmd)
+}
+
+func doRollback(cmd *cobra.Command, args []string) {
+
+	backups := listBackups()
+	if len(backups) == 0 {
+		check(errors.New("no backups found in " + getBackupsDir()))
+	}
+
+	fmt.Println("available backups:")
+	fmt.Println()
+	for _, b := range backups {
+		status := ""
+		if !b.Complete {
+			status = " (incomplete)"
+		}
+		fmt.Printf("\t%s\t%s\t%v file(s)\t%s%s\n", b.ID, b.Created.Format("2006-01-02 15:04:05"), len(b.Saved)+len(b.Added), b.Template, status)
+	}
+	fmt.Println()
+	if rollbackList {
+		return
+	}
+
+	//find the backup to restore
+	backup := backups[len(backups)-1]
+	if rollbackID != "" {
+		backup = nil
+		for _, b := range backups {
+			if b.ID == rollbackID {
+				backup = b
+			}
+		}
+		if backup == nil {
+			check(errors.New("backup not found: " + rollbackID))
+		}
+	}
+
+	if !yesUseDefaults {
+		fmt.Printf("Restore %s? ", backup.ID)
+		if !askForConfirmation() {
+			return
+		}
+	}
+	backup.restore()
+
+	//a restored backup is no longer needed
+	err := os.RemoveAll(backup.dir())
+	check(err)
+
+	fmt.Println()
+	fmt.Println("restored", backup.ID)
+}
@@ -14,6 +14,7 @@ import (
 
 const (
 	tempDir                 = "fargate-create-template"
+	stagingDir              = "fargate-create-staging"
 	templateConfigFile      = "fargate-create.yml"
 	targetInfrastructureDir = "iac"
 	varFormatHCL            = ".tfvars"
@@ -86,6 +87,7 @@ type scaffoldContext struct {
 	Region        string
 	Format        string
 	ContainerPort string
+	TemplateType  string
 }
 
 func (context scaffoldContext) GetApp() string {
@@ -104,10 +106,19 @@ func (context scaffoldContext) GetRegion() string {
 	return context.Region
 }
 
+func (context scaffoldContext) GetTemplateType() string {
+	return context.TemplateType
+}
+
+func (context scaffoldContext) GetProfile() string {
+	return context.Profile
+}
+
 //gets run before every command
 func persistentPreRun(cmd *cobra.Command, args []string) {
 
-	if !(cmd.Name() == "fargate-create" || cmd.Name() == "build") {
+	//validating existing build artifacts doesn't need the context
+	if !(cmd.Name() == "fargate-create" || (cmd.Name() == "build" && !buildValidate)) {
 		return
 	}
 
@@ -147,6 +158,7 @@ func persistentPreRun(cmd *cobra.Command, args []string) {
 		AccountID:     accountID,
 		Format:        varFormat,
 		ContainerPort: containerPort,
+		TemplateType:  getInstalledTemplateType(filepath.Join(targetDir, envDir, env)),
 	}
 }
 
@@ -5,7 +5,6 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
-	"strings"
 
 	getter "github.com/hashicorp/go-getter"
 	yaml "gopkg.in/yaml.v2"
@@ -29,9 +28,19 @@ const baseDir = "base"
 const envDir = "env"
 const devDir = "dev"
 
+//application files generated in each environment directory
+const dockerComposeFile = "docker-compose.yml"
+const fargateYamlFile = "fargate.yml"
+const deployScriptFile = "deploy.sh"
+const hiddenEnvFile = "hidden.env"
+
+var applicationFiles = []string{dockerComposeFile, fargateYamlFile, deployScriptFile, hiddenEnvFile}
+
 type templateConfig struct {
-	TemplateType string    `yaml:"templateType"`
-	Prompts      []*prompt `yaml:"prompts"`
+	TemplateType string         `yaml:"templateType"`
+	Prompts      []*prompt      `yaml:"prompts"`
+	Upgrade      *upgradeConfig `yaml:"upgrade"`
+	Build        *buildConfig   `yaml:"build"`
 }
 
 type prompt struct {
@@ -40,20 +49,46 @@ type prompt struct {
 	FilesToDeleteIfNo []string `yaml:"filesToDeleteIfNo"`
 }
 
+//upgradeConfig specifies the files that the upgrade command keeps up to date
+type upgradeConfig struct {
+	Include []string `yaml:"include"`
+	Exclude []string `yaml:"exclude"`
+}
+
+//buildConfig specifies the build artifacts supplied by a template
+type buildConfig struct {
+	//Dir contains a directory of go templates per build provider
+	Dir string `yaml:"dir"`
+}
+
+//the default directory of a template's build providers
+const defaultBuildDir = "build"
+
 func scaffold(context *scaffoldContext) {
+	lock := loadTemplateLock()
+	lock.Template = templateURL
 
 	//scaffold out infrastructure files
-	template := scaffoldInfrastructure(context)
+	template := scaffoldInfrastructure(context, lock)
 
 	//scaffold application files
 	scaffoldApplication(context, template)
 
 	//apply any template configurations
-	applyTemplateConfiguration(template.Base)
-	applyTemplateConfiguration(template.Env)
+	applyTemplateConfiguration(template.Base, lock)
+	applyTemplateConfiguration(template.Env, lock)
+
+	//pick up template customizations and deletions (in the directories that were just installed)
+	if template.Base.Installed {
+		lock.refreshDirectory(template.Base.Directory)
+	}
+	if template.Env.Installed {
+		lock.refreshDirectory(template.Env.Directory)
+	}
+	lock.save()
 }
 
-func applyTemplateConfiguration(t templateDirectory) {
+func applyTemplateConfiguration(t templateDirectory, lock *templateLock) {
 	if t.Configuration != nil {
 		for _, prompt := range t.Configuration.Prompts {
 			//if -y, use defaults, otherwise prompt
@@ -70,19 +105,20 @@ func applyTemplateConfiguration(t templateDirectory) {
 					fmt.Println("deleting ", p)
 					err := os.Remove(p)
 					check(err)
+					lock.decline(p, prompt.Question)
 				}
 			}
 		}
 	}
 }
 
-func scaffoldInfrastructure(context *scaffoldContext) *scaffoldTemplate {
+func scaffoldInfrastructure(context *scaffoldContext, lock *templateLock) *scaffoldTemplate {
 
 	//fetch terraform template
 	templateDir := downloadTerraformTemplate()
 	debug("downloaded to:", templateDir)
 
-	result := installTerraformTemplate(templateDir, context.Env)
+	result := installTerraformTemplate(templateDir, context.Env, lock)
 	debug("environment installed to:", result.Env.Directory)
 
 	//copy var file into base module
@@ -123,7 +159,8 @@ func downloadTerraformTemplate() string {
 		Mode: getter.ClientModeAny,
 	}
 
-	fmt.Println("downloading terraform template", templateURL)
+	//progress is written to stderr so that stdout can be used for reports
+	fmt.Fprintln(os.Stderr, "downloading terraform template", templateURL)
 	err := client.Get()
 	check(err)
 	debug("done")
@@ -132,7 +169,8 @@ func downloadTerraformTemplate() string {
 }
 
 //installs a template for the specified environment and returns a scaffoldTemplate
-func installTerraformTemplate(templateDir string, environment string) *scaffoldTemplate {
+//(installed files are recorded in the lock)
+func installTerraformTemplate(templateDir string, environment string, lock *templateLock) *scaffoldTemplate {
 
 	result := scaffoldTemplate{
 		Base: templateDirectory{},
@@ -157,6 +195,7 @@ func installTerraformTemplate(templateDir string, environment string) *scaffoldT
 		debug(fmt.Sprintf("copying %s to %s", sourceBaseDir, destBaseDir))
 		err = copyDir(sourceBaseDir, destBaseDir)
 		check(err)
+		lock.recordDirectory(destBaseDir)
 
 		result.Base.Installed = true
 		result.Base.Directory = destBaseDir
@@ -181,6 +220,7 @@ func installTerraformTemplate(templateDir string, environment string) *scaffoldT
 			//delete environment directory (all files)
 			err = os.RemoveAll(destEnvDir)
 			check(err)
+			lock.forgetDirectory(destEnvDir)
 		}
 	} else {
 		//doesn't exist
@@ -193,6 +233,7 @@ func installTerraformTemplate(templateDir string, environment string) *scaffoldT
 		debug(fmt.Sprintf("copying %s to %s", sourceEnvDir, destEnvDir))
 		err := copyDir(sourceEnvDir, destEnvDir)
 		check(err)
+		lock.recordDirectory(destEnvDir)
 
 		result.Env.Installed = true
 		result.Env.Directory = destEnvDir
@@ -201,6 +242,18 @@ func installTerraformTemplate(templateDir string, environment string) *scaffoldT
 		result.Env.Configuration = loadTemplateConfig(result.Env.Directory)
 	}
 
+	//record the version of the template that was installed
+	//(modules that were already installed keep their version)
+	version := getTemplateVersion(templateDir, templateURL)
+	lock.backfillModuleVersions(getInstalledModules())
+	if result.Base.Installed {
+		lock.setModuleVersion(result.Base.Directory, version)
+		lock.Version = version
+	}
+	if result.Env.Installed {
+		lock.setModuleVersion(result.Env.Directory, version)
+	}
+
 	// finally, delete temp dir
 	debug("deleting:", tempDir)
 	err := os.RemoveAll(tempDir)
@@ -231,6 +284,15 @@ func loadTemplateConfig(dir string) *templateConfig {
 	return &config
 }
 
+//returns the template type of an installed module
+func getInstalledTemplateType(dir string) string {
+	config := loadTemplateConfig(dir)
+	if config == nil {
+		return defaultTemplateType
+	}
+	return config.TemplateType
+}
+
 func getTargetVarFile(format string) string {
 	targetFile := ""
 	if format == varFormatHCL {
@@ -246,39 +308,48 @@ func scaffoldApplication(context *scaffoldContext, t *scaffoldTemplate) {
 
 	//write the application files to the env directory
 	targetAppDir := t.Env.Directory
+	writeApplicationFiles(context, t.Env.Configuration, targetAppDir)
+
+	//ignored files
+	ignoredFiles := []string{hiddenEnvFile, ".terraform"}
+	ensureFileContains(".gitignore", ignoredFiles)
+	ensureFileContains(".dockerignore", ignoredFiles)
+}
+
+//writes the files used to build and deploy the application to a directory
+func writeApplicationFiles(context *scaffoldContext, config *templateConfig, targetAppDir string) {
+
+	//templates without a fargate-create.yml are services
+	if config == nil {
+		config = &templateConfig{TemplateType: defaultTemplateType}
+	}
 
 	//write a docker-compose.yml file
 	dockerComposeYml := getDockerComposeYml(context)
-	dockerComposeYmlFile := filepath.Join(targetAppDir, "docker-compose.yml")
+	dockerComposeYmlFile := filepath.Join(targetAppDir, dockerComposeFile)
 	debug("writing", dockerComposeYmlFile)
 	err := ioutil.WriteFile(dockerComposeYmlFile, []byte(dockerComposeYml), 0644)
 	check(err)
 
 	//write hidden.env
-	hiddenEnvFileName := filepath.Join(targetAppDir, "hidden.env")
+	hiddenEnvFileName := filepath.Join(targetAppDir, hiddenEnvFile)
 	sampleContents := "#FOO=bar\n"
 	err = ioutil.WriteFile(hiddenEnvFileName, []byte(sampleContents), 0644)
 	check(err)
 
 	//write a fargate.yml for the cli
-	fargateYml := getFargateYaml(context, t.Env.Configuration)
-	fargateYmlFile := filepath.Join(targetAppDir, "fargate.yml")
+	fargateYml := getFargateYaml(context, config)
+	fargateYmlFile := filepath.Join(targetAppDir, fargateYamlFile)
 	debug("writing", fargateYmlFile)
 	err = ioutil.WriteFile(fargateYmlFile, []byte(fargateYml), 0644)
 	check(err)
 
 	//write deploy.sh
-	deployScript := getDeployScript(context, t.Env.Configuration)
-	deployScriptFile := filepath.Join(targetAppDir, "deploy.sh")
-	debug("writing", deployScriptFile)
-	err = ioutil.WriteFile(deployScriptFile, []byte(deployScript), 0755)
+	deployScript := getDeployScript(context, config)
+	deployScriptFileName := filepath.Join(targetAppDir, deployScriptFile)
+	debug("writing", deployScriptFileName)
+	err = ioutil.WriteFile(deployScriptFileName, []byte(deployScript), 0755)
 	check(err)
-
-	//ignored files
-	hiddenenv := strings.Split(hiddenEnvFileName, "/")
-	ignoredFiles := []string{hiddenenv[len(hiddenenv)-1], ".terraform"}
-	ensureFileContains(".gitignore", ignoredFiles)
-	ensureFileContains(".dockerignore", ignoredFiles)
 }
 
 func getFargateYaml(context *scaffoldContext, config *templateConfig) string {
@@ -160,3 +160,27 @@ func TestDockerComposeYml_NoPort(t *testing.T) {
 		t.Errorf("not expected: %s; actual: %s", notexpected, yml)
 	}	
 }
+
+func TestInstalledTemplateType(t *testing.T) {
+
+	//arrange
+	teardownTestCase := setupTestCase(t)
+	defer teardownTestCase(t)
+
+	//act/assert
+	//modules without a fargate-create.yml are services
+	actual := getInstalledTemplateType(tmpDir)
+	if actual != templateTypeService {
+		t.Errorf("expected: %s; actual: %s", templateTypeService, actual)
+	}
+
+	config := []byte("templateType: ScheduledTask\n")
+	err := ioutil.WriteFile(filepath.Join(tmpDir, templateConfigFile), config, 0644)
+	if err != nil {
+		t.Error(err)
+	}
+	actual = getInstalledTemplateType(tmpDir)
+	if actual != templateTypeScheduledTask {
+		t.Errorf("expected: %s; actual: %s", templateTypeScheduledTask, actual)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"regexp"
+	"sort"
 	"strings"
 )
 
@@ -21,13 +23,15 @@ func parseInputVarsJSON(input string) (string, string, string, string, string, e
 	var data map[string]interface{}
 
 	err := json.Unmarshal([]byte(input), &data)
-	check(err)
+	if err != nil {
+		return "", "", "", "", "", err
+	}
 
-	app := data["app"].(string)
-	environment := data["environment"].(string)
-	profile := data["aws_profile"].(string)
-	region := data["region"].(string)
-	containerPort := data["container_port"].(string)
+	app := getJSONString(data, "app")
+	environment := getJSONString(data, "environment")
+	profile := getJSONString(data, "aws_profile")
+	region := getJSONString(data, "region")
+	containerPort := getJSONString(data, "container_port")
 
 	//did we find it?
 	if app == "" {
@@ -46,6 +50,18 @@ func parseInputVarsJSON(input string) (string, string, string, string, string, e
 	return app, environment, profile, region, containerPort, nil
 }
 
+//returns a top level JSON value as a string (e.g., "container_port": 8080)
+func getJSONString(data map[string]interface{}, key string) string {
+	value, ok := data[key]
+	if !ok || value == nil {
+		return ""
+	}
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return fmt.Sprint(value)
+}
+
 func parseInputVarsHCL(tf string) (string, string, string, string, string, error) {
 	app := ""
 	environment := ""
@@ -147,3 +163,215 @@ func updateTerraformBackend(tf string, profile string, app string, env string, r
 	}
 	return newTf
 }
+
+//parseTerraformBackend returns the attributes of a terraform backend block, e.g.,
+//backend "s3" { bucket = "tf-state-my-app" }
+func parseTerraformBackend(tf string) map[string]string {
+	result := map[string]string{}
+	depth := 0
+	for _, line := range strings.Split(tf, "\n") {
+		trimmed := strings.TrimSpace(line)
+		//ignore whitespace and comments
+		if trimmed == "" || strings.HasPrefix(trimmed, "//") || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if depth == 0 {
+			if strings.HasPrefix(trimmed, "backend ") && strings.HasSuffix(trimmed, "{") {
+				depth = 1
+			}
+			continue
+		}
+
+		//key = "value"
+		if depth == 1 {
+			parts := strings.SplitN(trimmed, "=", 2)
+			if len(parts) == 2 {
+				value := strings.TrimSpace(strings.Split(parts[1], "#")[0])
+				result[strings.TrimSpace(parts[0])] = strings.Trim(value, `"`)
+			}
+		}
+		depth += strings.Count(trimmed, "{") - strings.Count(trimmed, "}")
+		if depth <= 0 {
+			break
+		}
+	}
+	return result
+}
+
+//variableDeclaration represents a terraform input variable declared by a template
+type variableDeclaration struct {
+	Name        string
+	Description string
+	HasDefault  bool
+}
+
+var variableBlockRegex = regexp.MustCompile(`^variable\s+"?([\w-]+)"?\s*\{(.*)$`)
+var defaultAttributeRegex = regexp.MustCompile(`(^|[{\s])default\s*=`)
+var descriptionAttributeRegex = regexp.MustCompile(`(^|[{\s])description\s*=\s*"([^"]*)"`)
+
+//parseVariableDeclarations returns the variables declared in a .tf file
+func parseVariableDeclarations(tf string) []*variableDeclaration {
+	result := []*variableDeclaration{}
+	var current *variableDeclaration
+	depth := 0
+	for _, line := range strings.Split(tf, "\n") {
+		trimmed := strings.TrimSpace(line)
+		//ignore whitespace and comments
+		if trimmed == "" || strings.HasPrefix(trimmed, "//") || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		//variable "name" {
+		if current == nil {
+			match := variableBlockRegex.FindStringSubmatch(trimmed)
+			if match == nil {
+				continue
+			}
+			current = &variableDeclaration{Name: match[1]}
+			trimmed = match[2]
+			depth = 1
+		}
+
+		//only look at attributes of the variable block itself
+		if depth == 1 {
+			if defaultAttributeRegex.MatchString(trimmed) {
+				current.HasDefault = true
+			}
+			if match := descriptionAttributeRegex.FindStringSubmatch(trimmed); match != nil {
+				current.Description = match[2]
+			}
+		}
+
+		depth += strings.Count(trimmed, "{") - strings.Count(trimmed, "}")
+		if depth <= 0 {
+			result = append(result, current)
+			current = nil
+		}
+	}
+	return result
+}
+
+//parseVarNames returns the names of the top level variables in a var file
+func parseVarNames(format string, input string) ([]string, error) {
+	if format == varFormatHCL {
+		return parseVarNamesHCL(input), nil
+	}
+	if format == varFormatJSON {
+		var data map[string]interface{}
+		err := json.Unmarshal([]byte(input), &data)
+		if err != nil {
+			return nil, err
+		}
+		result := []string{}
+		for key := range data {
+			result = append(result, key)
+		}
+		sort.Strings(result)
+		return result, nil
+	}
+	return nil, errors.New(`unknown var format: "` + format + `"`)
+}
+
+func parseVarNamesHCL(tf string) []string {
+	result := []string{}
+	depth := 0
+	for _, line := range strings.Split(tf, "\n") {
+		trimmed := strings.TrimSpace(line)
+		//ignore whitespace and comments
+		if trimmed == "" || strings.HasPrefix(trimmed, "//") || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		//key = value (ignoring nested maps and lists)
+		if depth == 0 {
+			parts := strings.SplitN(trimmed, "=", 2)
+			if len(parts) == 2 {
+				result = append(result, strings.Trim(strings.TrimSpace(parts[0]), `"`))
+			}
+		}
+		depth += strings.Count(trimmed, "{") + strings.Count(trimmed, "[")
+		depth -= strings.Count(trimmed, "}") + strings.Count(trimmed, "]")
+	}
+	return result
+}
+
+//addVar returns a var file with a new variable appended to it
+func addVar(format string, input string, name string, value string, comment string) string {
+	//strings are quoted, lists and maps are written as is
+	if !(strings.HasPrefix(value, "[") || strings.HasPrefix(value, "{")) {
+		b, _ := json.Marshal(value)
+		value = string(b)
+	}
+
+	if format == varFormatJSON {
+		//insert before the closing brace
+		trimmed := strings.TrimSpace(input)
+		trimmed = strings.TrimSpace(strings.TrimSuffix(trimmed, "}"))
+		separator := ","
+		if trimmed == "{" || trimmed == "" {
+			trimmed = "{"
+			separator = ""
+		}
+		return fmt.Sprintf("%s%s\n  \"%s\": %s\n}\n", trimmed, separator, name, value)
+	}
+
+	result := input
+	if !strings.HasSuffix(result, "\n") {
+		result += "\n"
+	}
+	result += "\n"
+	for _, line := range strings.Split(comment, "\n") {
+		result += "# " + line + "\n"
+	}
+	return result + fmt.Sprintf("%s = %s\n", name, value)
+}
+
+//parseVarValues returns the top level string values in a var file
+//(nested maps and lists are ignored)
+func parseVarValues(format string, input string) (map[string]string, error) {
+	result := map[string]string{}
+	if format == varFormatJSON {
+		var data map[string]interface{}
+		err := json.Unmarshal([]byte(input), &data)
+		if err != nil {
+			return nil, err
+		}
+		for key := range data {
+			result[key] = getJSONString(data, key)
+		}
+		return result, nil
+	}
+	if format != varFormatHCL {
+		return nil, errors.New(`unknown var format: "` + format + `"`)
+	}
+	depth := 0
+	for _, line := range strings.Split(input, "\n") {
+		trimmed := strings.TrimSpace(line)
+		//ignore whitespace and comments
+		if trimmed == "" || strings.HasPrefix(trimmed, "//") || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if depth == 0 {
+			parts := strings.SplitN(trimmed, "=", 2)
+			if len(parts) == 2 {
+				key := strings.Trim(strings.TrimSpace(parts[0]), `"`)
+				value := strings.TrimSpace(parts[1])
+				if strings.HasPrefix(value, `"`) {
+					//"foo" #comment
+					if end := strings.Index(value[1:], `"`); end >= 0 {
+						value = value[1 : end+1]
+					}
+				} else {
+					//remove trailing comments (count = 1 #comment)
+					value = strings.TrimSpace(strings.Split(value, "#")[0])
+				}
+				if !(strings.HasPrefix(value, "{") || strings.HasPrefix(value, "[")) {
+					result[key] = value
+				}
+			}
+		}
+		depth += strings.Count(trimmed, "{") + strings.Count(trimmed, "[")
+		depth -= strings.Count(trimmed, "}") + strings.Count(trimmed, "]")
+	}
+	return result, nil
+}
@@ -298,3 +298,198 @@ func TestParseInputVars_JSON(t *testing.T) {
 	}
 
 }
+
+func TestParseInputVars_JSON_OptionalContainerPort(t *testing.T) {
+
+	tf := `{
+	"region": "us-east-2",
+	"aws_profile": "default",
+	"app": "my-app",
+	"environment": "qa",
+	"replicas": 2
+}
+`
+	app, env, profile, region, containerPort, err := parseInputVars(varFormatJSON, tf)
+	if err != nil {
+		t.Error(err)
+	}
+
+	expected := "my-app"
+	if app != expected {
+		t.Errorf("expected: %s; actual: %s", expected, app)
+	}
+	expected = "qa"
+	if env != expected {
+		t.Errorf("expected: %s; actual: %s", expected, env)
+	}
+	expected = "default"
+	if profile != expected {
+		t.Errorf("expected: %s; actual: %s", expected, profile)
+	}
+	expected = "us-east-2"
+	if region != expected {
+		t.Errorf("expected: %s; actual: %s", expected, region)
+	}
+	expected = ""
+	if containerPort != expected {
+		t.Errorf("expected: %s; actual: %s", expected, containerPort)
+	}
+}
+
+func TestParseVariableDeclarations(t *testing.T) {
+
+	tf := `
+variable "app" {}
+
+variable "environment" {
+  description = "the environment (e.g., dev, qa, prod)"
+}
+
+# variable "commented" {}
+
+variable "replicas" {
+  description = "how many containers"
+  default     = "1"
+}
+
+variable "tags" {
+  type = map(string)
+  default = {
+    foo = "bar"
+  }
+}
+
+variable "subnets" {
+  type = list(string)
+
+  validation {
+    condition     = length(var.subnets) > 0
+    error_message = "at least one subnet is required"
+  }
+}
+
+variable "inline" { default = "foo" }
+
+resource "aws_ecs_cluster" "app" {
+  name = "${var.app}-${var.environment}"
+}
+`
+	vars := parseVariableDeclarations(tf)
+	for _, v := range vars {
+		t.Log(v.Name, v.HasDefault, v.Description)
+	}
+
+	expected := []struct {
+		name       string
+		hasDefault bool
+	}{
+		{"app", false},
+		{"environment", false},
+		{"replicas", true},
+		{"tags", true},
+		{"subnets", false},
+		{"inline", true},
+	}
+	if len(vars) != len(expected) {
+		t.Fatalf("expected: %v variables; actual: %v", len(expected), len(vars))
+	}
+	for i, e := range expected {
+		if vars[i].Name != e.name {
+			t.Errorf("expected: %s; actual: %s", e.name, vars[i].Name)
+		}
+		if vars[i].HasDefault != e.hasDefault {
+			t.Errorf("%s expected default: %v; actual: %v", e.name, e.hasDefault, vars[i].HasDefault)
+		}
+	}
+	if vars[1].Description != "the environment (e.g., dev, qa, prod)" {
+		t.Errorf("not expecting description: %s", vars[1].Description)
+	}
+}
+
+func TestParseVarNames(t *testing.T) {
+
+	tf := `
+app = "my-app" #comment
+environment = "qa"
+tags = {
+  application = "my-app"
+  environment = "qa"
+}
+subnets = [
+  "subnet-123",
+]
+region = "us-east-1"
+`
+	names, err := parseVarNames(varFormatHCL, tf)
+	if err != nil {
+		t.Error(err)
+	}
+	t.Log(names)
+
+	expected := "app,environment,tags,subnets,region"
+	if strings.Join(names, ",") != expected {
+		t.Errorf("expected: %s; actual: %s", expected, strings.Join(names, ","))
+	}
+}
+
+func TestAddVar(t *testing.T) {
+
+	hcl := addVar(varFormatHCL, `app = "my-app"`, "vpc", "vpc-123", "added by fargate-create upgrade\nthe vpc")
+	t.Log(hcl)
+	expected := "app = \"my-app\"\n\n# added by fargate-create upgrade\n# the vpc\nvpc = \"vpc-123\"\n"
+	if hcl != expected {
+		t.Errorf("expected: %s; actual: %s", expected, hcl)
+	}
+
+	json := addVar(varFormatJSON, "{\n  \"app\": \"my-app\"\n}\n", "vpc", "vpc-123", "")
+	t.Log(json)
+	values, err := parseVarValues(varFormatJSON, json)
+	if err != nil {
+		t.Error(err)
+	}
+	if values["app"] != "my-app" || values["vpc"] != "vpc-123" {
+		t.Errorf("not expecting: %s", json)
+	}
+}
+
+func TestParseTerraformBackend(t *testing.T) {
+
+	tf := `
+terraform {
+  required_version = ">= 0.12"
+
+  backend "s3" {
+    region  = "us-west-2"
+    profile = "my-profile"
+    bucket  = "tf-state-my-app"
+    key     = "dev.terraform.tfstate" # the state file
+  }
+}
+
+provider "aws" {
+  region  = var.region
+  profile = var.aws_profile
+}
+`
+	backend := parseTerraformBackend(tf)
+	t.Log(backend)
+
+	expected := map[string]string{
+		"region":  "us-west-2",
+		"profile": "my-profile",
+		"bucket":  "tf-state-my-app",
+		"key":     "dev.terraform.tfstate",
+	}
+	if len(backend) != len(expected) {
+		t.Errorf("expected: %d; actual: %d", len(expected), len(backend))
+	}
+	for k, v := range expected {
+		if backend[k] != v {
+			t.Errorf("expected: %s; actual: %s", v, backend[k])
+		}
+	}
+
+	if len(parseTerraformBackend(`provider "aws" {}`)) != 0 {
+		t.Error("not expecting a backend")
+	}
+}
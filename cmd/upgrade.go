@@ -9,12 +9,26 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/spf13/cobra"
 )
 
 var upgradeYes bool
+var upgradeCheck bool
+var upgradeReportFormat string
+var upgradeAnswersFile string
+var upgradeTo string
+var upgradeOnly string
+var upgradeEnvs []string
+var upgradeExcludeEnvs []string
+
+const upgradeOnlyBase = "base"
+const upgradeOnlyEnv = "env"
+
+//matches the account id in an ECR image (e.g., 123456789012.dkr.ecr.us-east-1.amazonaws.com/my-app:0.1.0)
+var ecrImageRegex = regexp.MustCompile(`image:\s*"?([^.\s"]+)\.dkr\.ecr\.`)
 
 var upgradeCmd = &cobra.Command{
 	Use:   "upgrade",
@@ -24,10 +38,24 @@ var upgradeCmd = &cobra.Command{
 fargate-create upgrade
 fargate-create upgrade -t git@github.com:turnerlabs/terraform-ecs-fargate-scheduled-task
 fargate-create upgrade -d infrastructure
+fargate-create upgrade --check
+fargate-create upgrade --check --format markdown
+fargate-create upgrade --answers new-vars.tfvars
+fargate-create upgrade --to v0.5.0
+fargate-create upgrade --only base
+fargate-create upgrade --env dev,qa
+fargate-create upgrade --exclude-env prod
 `,
 }
 
 func init() {
+	upgradeCmd.Flags().BoolVar(&upgradeCheck, "check", false, "report available upgrades without changing any files (exits with 2 if upgrades are available)")
+	upgradeCmd.Flags().StringVar(&upgradeReportFormat, "format", reportFormatJSON, "format of the --check report (json or markdown)")
+	upgradeCmd.Flags().StringVar(&upgradeAnswersFile, "answers", "", "file (.tfvars or .json) with values for new variables required by the template")
+	upgradeCmd.Flags().StringVar(&upgradeTo, "to", "", "upgrade to a specific template version (git ref)")
+	upgradeCmd.Flags().StringVar(&upgradeOnly, "only", "", "only upgrade the base module or the environments (base or env)")
+	upgradeCmd.Flags().StringSliceVar(&upgradeEnvs, "env", nil, "only upgrade these environments (e.g., dev,qa)")
+	upgradeCmd.Flags().StringSliceVar(&upgradeExcludeEnvs, "exclude-env", nil, "don't upgrade these environments (e.g., prod)")
 	rootCmd.AddCommand(upgradeCmd)
 }
 
@@ -45,54 +73,154 @@ func doUpgrade(cmd *cobra.Command, args []string) {
 		check(errors.New("no existing template found"))
 	}
 
+	//validate report format up front
+	if upgradeCheck && !(upgradeReportFormat == reportFormatJSON || upgradeReportFormat == reportFormatMarkdown) {
+		check(errors.New("unknown report format: " + upgradeReportFormat))
+	}
+
+	//which modules are being upgraded?
+	if !(upgradeOnly == "" || upgradeOnly == upgradeOnlyBase || upgradeOnly == upgradeOnlyEnv) {
+		check(errors.New("--only must be either base or env"))
+	}
+	if upgradeOnly == upgradeOnlyBase && len(upgradeEnvs) > 0 {
+		check(errors.New("--only base can't be used with --env"))
+	}
+	upgradeBase := upgradeOnly != upgradeOnlyEnv
+	environments, err := getUpgradeEnvironments()
+	check(err)
+	modules := []string{}
+	if upgradeBase {
+		modules = append(modules, filepath.Join(targetDir, baseDir))
+	}
+	for _, e := range environments {
+		modules = append(modules, filepath.Join(targetDir, envDir, e))
+	}
+
+	//the lock tells us which template (and version) was installed
+	//and which installed files came from it
+	lock := loadTemplateLock()
+	installedVersion := lock.getInstalledVersion(modules)
+
+	//unless otherwise specified, upgrade the installed template
+	if !cmd.Flags().Changed("template") && lock.Template != "" {
+		templateURL = setTemplateRef(lock.Template, "")
+	}
+	if upgradeTo != "" {
+		templateURL = setTemplateRef(templateURL, upgradeTo)
+	}
+
 	//fetch the template from the source
 	templateDir := downloadTerraformTemplate()
 	debug("downloaded to:", templateDir)
 
-	//process /base first, then iterate over /env
-	srcDir := filepath.Join(templateDir, baseDir)
-	destDir := filepath.Join(targetDir, baseDir)
-	adds, updates := upgradeDirectory(srcDir, destDir)
+	//show what's changed between versions
+	version := getTemplateVersion(templateDir, templateURL)
+	if !upgradeCheck {
+		showTemplateChanges(templateDir, installedVersion, version)
+	}
 
-	//process each installed environment
-	srcDir = filepath.Join(templateDir, envDir, devDir)
-	objects, err := ioutil.ReadDir(filepath.Join(targetDir, envDir))
-	check(err)
-	for _, o := range objects {
-		if o.IsDir() {
-			destDir = filepath.Join(targetDir, envDir, o.Name())
-			debug(destDir)
-
-			//look for default terraform.tfvars file for this environment
-			tfVarsFile := filepath.Join(destDir, "terraform.tfvars")
-			debug(tfVarsFile)
-			if _, err = os.Stat(tfVarsFile); os.IsNotExist(err) {
-				check(errors.New(tfVarsFile + " not found"))
-			}
+	//values for new required variables
+	answers := map[string]string{}
+	if upgradeAnswersFile != "" {
+		dat, err := ioutil.ReadFile(upgradeAnswersFile)
+		check(err)
+		answers, err = parseVarValues(getVarFormat(upgradeAnswersFile), string(dat))
+		check(err)
+	}
 
-			fileBits, err := ioutil.ReadFile(tfVarsFile)
-			check(err)
-			varFormat := strings.ToLower(filepath.Ext(tfVarsFile))
-			app, env, profile, region, _, err := parseInputVars(varFormat, string(fileBits))
-			check(err)
+	//snapshot files before they're touched so the upgrade can be rolled back
+	backup := newUpgradeBackup(templateURL)
 
-			//apply env transformation in src before upgrading
-			transformMainTFToContext(srcDir, profile, app, env, region)
+	report := upgradeReport{
+		Template:         templateURL,
+		InstalledVersion: installedVersion,
+		Version:          version,
+	}
+	adds := []string{}
+	updates := []string{}
+	deletes := []string{}
 
-			//upgrade env directory
-			a, u := upgradeDirectory(srcDir, destDir)
-			adds = append(adds, a...)
-			updates = append(updates, u...)
+	//process /base first, then iterate over /env
+	if upgradeBase {
+		srcDir := filepath.Join(templateDir, baseDir)
+		destDir := filepath.Join(targetDir, baseDir)
+		module := compareDirectory(srcDir, destDir, getUpgradeFilter(loadTemplateConfig(srcDir)), lock)
+
+		//the base var file is a copy of the original input file, so only look for new variables
+		if baseVarFile, baseVarFormat := findVarFile(destDir); baseVarFile != "" {
+			compareVariables(module, baseVarFile, baseVarFormat, false)
 		}
+		report.Modules = append(report.Modules, module)
 	}
 
-	//delete download dir
+	//process each selected environment
+	for _, environment := range environments {
+		destDir := filepath.Join(targetDir, envDir, environment)
+		debug(destDir)
+
+		envContext, tfVarsFile := loadEnvironmentContext(destDir)
+		varFormat := envContext.Format
+
+		//apply env transformation to a staged copy of src before upgrading
+		//so that each environment starts from the original template
+		srcDir := stageEnvironment(templateDir, environment)
+		transformMainTFToContext(srcDir, envContext.Profile, envContext.App, envContext.Env, envContext.Region)
+
+		//regenerate the application files in the staged copy so they get reviewed like template files
+		config := loadTemplateConfig(srcDir)
+		writeApplicationFiles(envContext, config, srcDir)
+
+		//upgrade env directory
+		filter := getUpgradeFilter(config)
+		filter.Include = append(filter.Include, applicationFiles...)
+		module := compareDirectory(srcDir, destDir, filter, lock)
+		compareVariables(module, tfVarsFile, varFormat, true)
+		report.Modules = append(report.Modules, module)
+	}
+
+	//in check mode, output the report and signal whether upgrades are available
+	if upgradeCheck {
+		os.RemoveAll(templateDir)
+		os.RemoveAll(stagingDir)
+		writeUpgradeReport(os.Stdout, &report, upgradeReportFormat)
+		if report.upgradesAvailable() {
+			os.Exit(exitCodeUpgradesAvailable)
+		}
+		return
+	}
+
+	//get the values of new variables before any files are touched
+	getVariableValues(report.Modules, answers)
+
+	for _, module := range report.Modules {
+		a, u, d := upgradeDirectory(module, lock, backup)
+		adds = append(adds, a...)
+		updates = append(updates, u...)
+		deletes = append(deletes, d...)
+	}
+
+	//delete download and staging dirs
 	os.RemoveAll(templateDir)
+	os.RemoveAll(stagingDir)
+
+	//modules that weren't upgraded keep the version they were installed with
+	lock.Template = templateURL
+	lock.backfillModuleVersions(getInstalledModules())
+	for _, m := range modules {
+		lock.setModuleVersion(m, version)
+	}
+	partial := upgradeOnly != "" || len(upgradeEnvs) > 0 || len(upgradeExcludeEnvs) > 0
+	if !partial {
+		lock.Version = version
+	}
+	backup.save(getLockFile())
+	lock.save()
+	backup.finish()
 
 	fmt.Println()
 	fmt.Println("---------------------------------------")
-	fmt.Printf("upgrade complete: %v add(s), %v update(s)\n", len(adds), len(updates))
-	if len(adds) > 0 || len(updates) > 0 {
+	fmt.Printf("upgrade complete: %v add(s), %v update(s), %v delete(s)\n", len(adds), len(updates), len(deletes))
+	if len(adds) > 0 || len(updates) > 0 || len(deletes) > 0 {
 		fmt.Println()
 		fmt.Println("updated files:")
 		fmt.Println()
@@ -106,98 +234,526 @@ func doUpgrade(cmd *cobra.Command, args []string) {
 			fmt.Printf("\t%s\n", s)
 		}
 		fmt.Println()
+		fmt.Println("deleted files:")
+		fmt.Println()
+		for _, s := range deletes {
+			fmt.Printf("\t%s\n", s)
+		}
+		fmt.Println()
 		fmt.Println(`run the following commands to apply these changes:
 terraform init -upgrade=true
 terraform apply`)
 	}
 }
 
-func upgradeDirectory(srcDir string, destDir string) ([]string, []string) {
+//getInstalledModules returns the installed base and environment directories
+func getInstalledModules() []string {
+	result := []string{}
+	if _, err := os.Stat(filepath.Join(targetDir, baseDir)); err == nil {
+		result = append(result, filepath.Join(targetDir, baseDir))
+	}
+	objects, err := ioutil.ReadDir(filepath.Join(targetDir, envDir))
+	if err != nil {
+		return result
+	}
+	for _, o := range objects {
+		if o.IsDir() {
+			result = append(result, filepath.Join(targetDir, envDir, o.Name()))
+		}
+	}
+	return result
+}
 
-	//prompt for updates to existing local files
-	//add new required files
-	//prompt to add new optional files (using fargate-create.yml)
+//getUpgradeEnvironments returns the installed environments selected for upgrade
+//(directories under env that contain a var file)
+func getUpgradeEnvironments() ([]string, error) {
+	result := []string{}
+	if upgradeOnly == upgradeOnlyBase {
+		return result, nil
+	}
+
+	installed := []string{}
+	objects, err := ioutil.ReadDir(filepath.Join(targetDir, envDir))
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range objects {
+		if !o.IsDir() {
+			continue
+		}
+		if f, _ := findVarFile(filepath.Join(targetDir, envDir, o.Name())); f == "" {
+			debug("no var file found, skipping", o.Name())
+			continue
+		}
+		installed = append(installed, o.Name())
+	}
+
+	//make sure specified environments exist
+	for _, e := range append(upgradeEnvs, upgradeExcludeEnvs...) {
+		if !containsString(installed, e) {
+			return nil, fmt.Errorf("environment not found: %s", filepath.Join(targetDir, envDir, e))
+		}
+	}
+
+	for _, e := range installed {
+		if len(upgradeEnvs) > 0 && !containsString(upgradeEnvs, e) {
+			debug("skipping environment", e)
+			continue
+		}
+		if containsString(upgradeExcludeEnvs, e) {
+			debug("excluding environment", e)
+			continue
+		}
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+//showTemplateChanges displays the template's changelog entries between versions
+//and confirms major version upgrades
+func showTemplateChanges(templateDir string, installed string, target string) {
+	if target == "" {
+		return
+	}
+	if installed != "" && !isSemver(installed) {
+		//e.g., installed from a branch before refs were checked
+		fmt.Fprintf(os.Stderr, "WARNING: the installed template version (%s) isn't a version, changelogs and major version upgrades can't be detected\n", installed)
+		installed = ""
+	}
+	if installed != "" && compareVersions(installed, target) == 0 {
+		return
+	}
 
 	fmt.Println()
 	fmt.Println("---------------------------------------")
-	fmt.Println("upgrading", destDir)
+	if installed == "" {
+		fmt.Println("upgrading to", target)
+	} else {
+		fmt.Printf("upgrading from %s to %s\n", installed, target)
+	}
 	fmt.Println("---------------------------------------")
+	for _, entry := range getChangelogEntries(loadChangelog(templateDir), installed, target) {
+		fmt.Println()
+		fmt.Println(entry.Notes)
+	}
 
-	updates := []string{}
-	adds := []string{}
+	if installed == "" {
+		return
+	}
+	if compareVersions(target, installed) < 0 {
+		fmt.Println()
+		fmt.Printf("WARNING: %s is older than the installed version (%s)\n", target, installed)
+	}
+	if majorVersion(target) > majorVersion(installed) {
+		fmt.Println()
+		fmt.Printf("WARNING: upgrading from %s to %s is a major version upgrade and may contain breaking changes\n", installed, target)
+		if !yesUseDefaults {
+			fmt.Print("Continue? ")
+			if !askForConfirmation() {
+				check(errors.New("upgrade cancelled"))
+			}
+		}
+	}
+}
+
+//findVarFile returns the var file installed in a directory along with its format
+//(returns an empty string if not found)
+func findVarFile(dir string) (string, string) {
+	for _, format := range []string{varFormatHCL, varFormatJSON} {
+		file := filepath.Join(dir, getTargetVarFile(format))
+		if _, err := os.Stat(file); err == nil {
+			return file, format
+		}
+	}
+	return "", ""
+}
+
+//loadEnvironmentContext builds a context from an installed environment's var file
+//and returns it along with the var file
+func loadEnvironmentContext(dir string) (*scaffoldContext, string) {
+	//look for the var file for this environment (terraform.tfvars or terraform.tfvars.json)
+	tfVarsFile, varFormat := findVarFile(dir)
+	if tfVarsFile == "" {
+		check(fmt.Errorf("%s or %s not found in %s", getTargetVarFile(varFormatHCL), getTargetVarFile(varFormatJSON), dir))
+	}
+	debug(tfVarsFile)
 
-	//iterate src files
-	files, err := ioutil.ReadDir(srcDir)
+	fileBits, err := ioutil.ReadFile(tfVarsFile)
 	check(err)
-	for _, f := range files {
-		file := f.Name()
-		debug(file)
+	app, env, profile, region, containerPort, err := parseInputVars(varFormat, string(fileBits))
+	check(err)
+
+	return &scaffoldContext{
+		App:           app,
+		Env:           env,
+		Profile:       profile,
+		Region:        region,
+		AccountID:     getEnvironmentAccountID(dir, profile),
+		Format:        varFormat,
+		ContainerPort: containerPort,
+		TemplateType:  getInstalledTemplateType(dir),
+	}, tfVarsFile
+}
+
+//returns the var format of a file based on its extension
+func getVarFormat(file string) string {
+	return strings.ToLower(filepath.Ext(file))
+}
+
+//compareVariables compares the variables declared by a module's template
+//with the ones in its var file
+func compareVariables(module *moduleReport, varFile string, varFormat string, flagRemoved bool) {
+	module.varFile = varFile
+	module.varFormat = varFormat
 
-		//only process .tf or .md files
-		if !(strings.HasSuffix(file, ".tf") || strings.HasSuffix(file, ".md") || strings.HasSuffix(file, ".tpl")) {
+	//parse the template's variable declarations
+	declared := map[string]bool{}
+	files, err := ioutil.ReadDir(module.srcDir)
+	check(err)
+	declarations := []*variableDeclaration{}
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != ".tf" {
 			continue
 		}
+		dat, err := ioutil.ReadFile(filepath.Join(module.srcDir, f.Name()))
+		check(err)
+		for _, v := range parseVariableDeclarations(string(dat)) {
+			declared[v.Name] = true
+			declarations = append(declarations, v)
+		}
+	}
+
+	//parse the var file
+	dat, err := ioutil.ReadFile(varFile)
+	check(err)
+	names, err := parseVarNames(varFormat, string(dat))
+	check(err)
+
+	for _, v := range declarations {
+		if !v.HasDefault && !containsString(names, v.Name) {
+			debug("new required variable:", v.Name)
+			module.NewVariables = append(module.NewVariables, v.Name)
+			module.newVariables = append(module.newVariables, v)
+		}
+	}
+	if flagRemoved {
+		for _, name := range names {
+			if !declared[name] {
+				debug("variable no longer declared:", name)
+				module.RemovedVariables = append(module.RemovedVariables, name)
+			}
+		}
+	}
+}
+
+//getVariableValue returns the value for a new required variable
+//from the answers file or by prompting the user
+//getVariableValues gets the values of the modules' new required variables (from the answers
+//or by prompting), failing if any are missing with -y
+func getVariableValues(modules []*moduleReport, answers map[string]string) {
+	if missing := getMissingAnswers(modules, answers); yesUseDefaults && len(missing) > 0 {
+		check(fmt.Errorf(`no value for new required variable(s) "%s", use --answers to specify them`, strings.Join(missing, `", "`)))
+	}
+	for _, m := range modules {
+		m.variableValues = map[string]string{}
+		for _, v := range m.newVariables {
+			m.variableValues[v.Name] = getVariableValue(v, answers)
+		}
+	}
+}
+
+//returns the names of the modules' new required variables that don't have answers
+func getMissingAnswers(modules []*moduleReport, answers map[string]string) []string {
+	result := []string{}
+	for _, m := range modules {
+		for _, v := range m.newVariables {
+			if _, ok := answers[v.Name]; !ok && !containsString(result, v.Name) {
+				result = append(result, v.Name)
+			}
+		}
+	}
+	return result
+}
+
+func getVariableValue(v *variableDeclaration, answers map[string]string) string {
+	if value, ok := answers[v.Name]; ok {
+		return value
+	}
+	fmt.Println()
+	if v.Description != "" {
+		fmt.Println(v.Description)
+	}
+	for {
+		value := promptAndGetLine(fmt.Sprintf(`value for new required variable "%s": `, v.Name))
+		if value != "" {
+			return value
+		}
+	}
+}
+
+//stageEnvironment copies the template's environment directory to a
+//staging directory for the specified environment and returns its path
+func stageEnvironment(templateDir string, environment string) string {
+	srcDir := filepath.Join(templateDir, envDir, devDir)
+	stageDir := filepath.Join(stagingDir, environment)
+	debug(fmt.Sprintf("staging %s to %s", srcDir, stageDir))
+	err := os.RemoveAll(stageDir)
+	check(err)
+	err = copyDir(srcDir, stageDir)
+	check(err)
+	return stageDir
+}
+
+//getEnvironmentAccountID returns the AWS account ID used by an installed environment
+//(read from its docker-compose.yml image, falling back to looking it up using the profile)
+func getEnvironmentAccountID(dir string, profile string) string {
+	dat, err := ioutil.ReadFile(filepath.Join(dir, dockerComposeFile))
+	if err == nil {
+		if match := ecrImageRegex.FindStringSubmatch(string(dat)); match != nil {
+			return match[1]
+		}
+	}
+	debug("looking up AWS Account ID using profile:", profile)
+	accountID, err := getAWSAccountID(profile)
+	check(err)
+	return accountID
+}
+
+//compareDirectory compares a template directory to an installed directory
+//without making any changes and returns the differences
+func compareDirectory(srcDir string, destDir string, filter *upgradeFilter, lock *templateLock) *moduleReport {
+	result := &moduleReport{
+		Module:  destDir,
+		Added:   []string{},
+		Changed: []string{},
+		Removed: []string{},
+
+		NewVariables:     []string{},
+		RemovedVariables: []string{},
+
+		srcDir: srcDir,
+	}
+	templateConfig := loadTemplateConfig(srcDir)
+
+	//walk src files
+	err := filepath.Walk(srcDir, func(source string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		file, err := filepath.Rel(srcDir, source)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if file != "." && filter.excludesDirectory(file) {
+				debug("skipping directory", file)
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		debug(file)
+		if !info.Mode().IsRegular() || !filter.includes(file) {
+			return nil
+		}
 
 		//does matching file in template exist?
-		source := filepath.Join(srcDir, file)
 		dest := filepath.Join(destDir, file)
 		debug(fmt.Sprintf("source: %s | dest: %s", source, dest))
 
-		//can we access the source file?
-		if _, err := os.Stat(source); err == nil {
-
-			//does corresponding dest file exist?
-			if _, err = os.Stat(dest); os.IsNotExist(err) {
-				debug("new source file")
-
-				//is this file required or optional?
-				//load template config file
-				templateConfig := loadTemplateConfig(srcDir)
-				if templateConfig != nil {
-					prompt := getFilePrompt(templateConfig, file)
-					if prompt != nil {
-						//prompt to install new optional file
-						fmt.Println()
-						q := fmt.Sprintf("%s (%s) ", prompt.Question, prompt.Default)
-						response := promptAndGetResponse(q, prompt.Default)
-						if containsString(okayResponses, response) {
-							err = copyFile(source, dest)
-							check(err)
-							adds = append(adds, dest)
-						}
-					} else {
-						//write new required file
-						fmt.Println("writing", dest)
-						copyFile(source, dest)
-						adds = append(adds, dest)
-					}
-				} else {
-					debug("no template config file found")
-				}
+		//does corresponding dest file exist?
+		if _, err := os.Stat(dest); os.IsNotExist(err) {
+			if isDeclinedFile(templateConfig, lock, file, dest) {
+				debug("optional file was declined")
+				return nil
+			}
+			debug("new source file")
+			result.Added = append(result.Added, file)
+		} else if file == hiddenEnvFile {
+			debug("never overwriting", dest)
+		} else {
+			//does dest file need updating?
+			debug("diffing")
+			if !deepCompare(source, dest) {
+				result.Changed = append(result.Changed, file)
 			} else {
-				//does dest file need updating?
-				debug("diffing")
-				if !deepCompare(source, dest) {
-					fmt.Println()
-					response := promptAndGetResponse(dest+" is out of date. Replace? (yes) ", "yes")
-					if containsString(okayResponses, response) {
-						err = copyFile(source, dest)
-						check(err)
-						updates = append(updates, dest)
-					}
+				debug("files match")
+				result.Unchanged = append(result.Unchanged, file)
+			}
+		}
+		return nil
+	})
+	check(err)
+
+	//iterate files installed by the template that no longer exist in src
+	//(files that aren't in the lock are user-authored and left alone)
+	for _, file := range lock.filesIn(destDir) {
+		if !filter.includes(file) {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(destDir, file)); os.IsNotExist(err) {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(srcDir, file)); os.IsNotExist(err) {
+			debug("file removed from source:", file)
+			result.Removed = append(result.Removed, file)
+		}
+	}
+
+	return result
+}
+
+func upgradeDirectory(module *moduleReport, lock *templateLock, backup *upgradeBackup) ([]string, []string, []string) {
+
+	//prompt for updates to existing local files
+	//add new required files
+	//prompt to add new optional files (using fargate-create.yml)
+	//prompt to delete files that were removed from the template
+	//add new required variables to the var file
+
+	srcDir := module.srcDir
+	destDir := module.Module
+
+	fmt.Println()
+	fmt.Println("---------------------------------------")
+	fmt.Println("upgrading", destDir)
+	fmt.Println("---------------------------------------")
+
+	updates := []string{}
+	adds := []string{}
+	deletes := []string{}
+
+	//is this file required or optional?
+	//load template config file
+	templateConfig := loadTemplateConfig(srcDir)
+
+	for _, file := range module.Added {
+		source := filepath.Join(srcDir, file)
+		dest := filepath.Join(destDir, file)
+		if templateConfig != nil {
+			prompt := getFilePrompt(templateConfig, file)
+			if prompt != nil {
+				//prompt to install new optional file
+				fmt.Println()
+				q := fmt.Sprintf("%s (%s) ", prompt.Question, prompt.Default)
+				response := promptAndGetResponse(q, prompt.Default)
+				if containsString(okayResponses, response) {
+					err := os.MkdirAll(filepath.Dir(dest), 0755)
+					check(err)
+					backup.save(dest)
+					err = copyFile(source, dest)
+					check(err)
+					lock.record(dest)
+					adds = append(adds, dest)
 				} else {
-					debug("files match")
+					lock.decline(dest, prompt.Question)
 				}
+			} else {
+				//write new required file
+				fmt.Println("writing", dest)
+				err := os.MkdirAll(filepath.Dir(dest), 0755)
+				check(err)
+				backup.save(dest)
+				err = copyFile(source, dest)
+				check(err)
+				lock.record(dest)
+				adds = append(adds, dest)
+			}
+		} else {
+			debug("no template config file found")
+		}
+	}
+
+	for _, file := range module.Changed {
+		source := filepath.Join(srcDir, file)
+		dest := filepath.Join(destDir, file)
+		fmt.Println()
+		q := dest + " is out of date. Replace? (yes) "
+		defaultResponse := "yes"
+		if lock.installed(dest) && lock.modified(dest) {
+			q = dest + " is out of date but has local changes. Replace? (no) "
+			defaultResponse = "no"
+		}
+		response := promptAndGetResponse(q, defaultResponse)
+		if containsString(okayResponses, response) {
+			backup.save(dest)
+			err := copyFile(source, dest)
+			check(err)
+			lock.record(dest)
+			updates = append(updates, dest)
+		} else if !lock.installed(dest) {
+			//keep treating the declined file as modified rather than
+			//recording the local contents as the template's
+			lock.recordUnknown(dest)
+		}
+	}
+
+	//files installed before checksums were recorded
+	for _, file := range module.Unchanged {
+		dest := filepath.Join(destDir, file)
+		if !lock.installed(dest) {
+			lock.record(dest)
+		}
+	}
+
+	for _, file := range module.Removed {
+		dest := filepath.Join(destDir, file)
+		fmt.Println()
+		q := dest + " was removed from the template. Delete? (yes) "
+		defaultResponse := "yes"
+		if lock.modified(dest) {
+			q = dest + " was removed from the template but has local changes. Delete? (no) "
+			defaultResponse = "no"
+		}
+		response := promptAndGetResponse(q, defaultResponse)
+		if containsString(okayResponses, response) {
+			backup.save(dest)
+			err := os.Remove(dest)
+			check(err)
+			lock.forget(dest)
+			deletes = append(deletes, dest)
+		}
+	}
+
+	if len(module.newVariables) > 0 {
+		dat, err := ioutil.ReadFile(module.varFile)
+		check(err)
+		vars := string(dat)
+		for _, v := range module.newVariables {
+			value := module.variableValues[v.Name]
+			comment := "added by fargate-create upgrade"
+			if v.Description != "" {
+				comment += "\n" + v.Description
 			}
+			fmt.Printf("adding %s to %s\n", v.Name, module.varFile)
+			vars = addVar(module.varFormat, vars, v.Name, value, comment)
 		}
+		backup.save(module.varFile)
+		err = ioutil.WriteFile(module.varFile, []byte(vars), 0644)
+		check(err)
+		updates = append(updates, module.varFile)
+	}
+
+	for _, name := range module.RemovedVariables {
+		fmt.Println()
+		fmt.Printf("WARNING: %s is no longer declared by the template and can be removed from %s\n", name, module.varFile)
+	}
+
+	return adds, updates, deletes
+}
+
+//returns true if an optional file was declined (and the question hasn't changed since)
+func isDeclinedFile(config *templateConfig, lock *templateLock, file string, dest string) bool {
+	if config == nil {
+		return false
 	}
-	return adds, updates
+	prompt := getFilePrompt(config, file)
+	return prompt != nil && lock.declined(dest, prompt.Question)
 }
 
 func getFilePrompt(config *templateConfig, file string) *prompt {
 	for _, p := range config.Prompts {
 		for _, f := range p.FilesToDeleteIfNo {
-			if f == file {
+			if f == filepath.ToSlash(file) {
 				return p
 			}
 		}
@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writes files (relative to a directory) for a test
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	for name, contents := range files {
+		f := filepath.Join(dir, name)
+		err := os.MkdirAll(filepath.Dir(f), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ioutil.WriteFile(f, []byte(contents), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+const testTemplateConfig = `prompts:
+  - question: "Would you like an HTTPS listener?"
+    default: "no"
+    filesToDeleteIfNo:
+      - "lb-https.tf"
+`
+
+func TestCompareDirectory(t *testing.T) {
+
+	//arrange
+	teardownTestCase := setupTestCase(t)
+	defer teardownTestCase(t)
+	src := filepath.Join(tmpDir, "src")
+	dest := filepath.Join(tmpDir, "dest")
+	writeTestFiles(t, src, map[string]string{
+		"fargate-create.yml": testTemplateConfig,
+		"main.tf":            "main",
+		"changed.tf":         "template change",
+		"added.tf":           "added",
+		"lb-https.tf":        "https",
+		"terraform.tfvars":   "app = \"my-app\"",
+	})
+	writeTestFiles(t, dest, map[string]string{
+		"main.tf":          "main",
+		"changed.tf":       "original",
+		"removed.tf":       "removed",
+		"user.tf":          "user-authored",
+		"terraform.tfvars": "app = \"my-app\"",
+	})
+	lock := &templateLock{Files: map[string]string{}}
+	for _, f := range []string{"main.tf", "changed.tf", "removed.tf"} {
+		lock.record(filepath.Join(dest, f))
+	}
+	lock.decline(filepath.Join(dest, "lb-https.tf"), "Would you like an HTTPS listener?")
+
+	//act
+	module := compareDirectory(src, dest, getUpgradeFilter(loadTemplateConfig(src)), lock)
+
+	//assert
+	expected := map[string][]string{
+		"added":     {"added.tf"},
+		"changed":   {"changed.tf"},
+		"removed":   {"removed.tf"},
+		"unchanged": {"main.tf"},
+	}
+	actual := map[string][]string{
+		"added":     module.Added,
+		"changed":   module.Changed,
+		"removed":   module.Removed,
+		"unchanged": module.Unchanged,
+	}
+	for k, e := range expected {
+		if strings.Join(actual[k], ",") != strings.Join(e, ",") {
+			t.Errorf("%s expected: %v; actual: %v", k, e, actual[k])
+		}
+	}
+}
+
+func TestCompareDirectoryDeclinedQuestionChanged(t *testing.T) {
+
+	//arrange
+	teardownTestCase := setupTestCase(t)
+	defer teardownTestCase(t)
+	src := filepath.Join(tmpDir, "src")
+	dest := filepath.Join(tmpDir, "dest")
+	writeTestFiles(t, src, map[string]string{
+		"fargate-create.yml": testTemplateConfig,
+		"lb-https.tf":        "https",
+	})
+	os.MkdirAll(dest, 0755)
+	lock := &templateLock{Files: map[string]string{}}
+	lock.decline(filepath.Join(dest, "lb-https.tf"), "Would you like HTTPS?")
+
+	//act
+	module := compareDirectory(src, dest, getUpgradeFilter(nil), lock)
+
+	//assert
+	if len(module.Added) != 1 || module.Added[0] != "lb-https.tf" {
+		t.Error("expecting lb-https.tf to be offered again", module.Added)
+	}
+}
+
+func TestGetVariableValues(t *testing.T) {
+
+	//arrange
+	modules := []*moduleReport{
+		{Module: "iac/base", newVariables: []*variableDeclaration{{Name: "vpc"}}},
+		{Module: "iac/env/dev", newVariables: []*variableDeclaration{{Name: "vpc"}, {Name: "subnets"}}},
+	}
+	answers := map[string]string{"vpc": "vpc-123", "subnets": "subnet-1,subnet-2"}
+
+	//act
+	getVariableValues(modules, answers)
+
+	//assert
+	if modules[1].variableValues["subnets"] != "subnet-1,subnet-2" || modules[0].variableValues["vpc"] != "vpc-123" {
+		t.Error("not expecting", modules[0].variableValues, modules[1].variableValues)
+	}
+}
+
+func TestGetMissingAnswers(t *testing.T) {
+	modules := []*moduleReport{
+		{Module: "iac/base", newVariables: []*variableDeclaration{{Name: "vpc"}}},
+		{Module: "iac/env/dev", newVariables: []*variableDeclaration{{Name: "vpc"}, {Name: "subnets"}}},
+		{Module: "iac/env/prod", newVariables: []*variableDeclaration{{Name: "subnets"}}},
+	}
+
+	missing := getMissingAnswers(modules, map[string]string{"vpc": "vpc-123"})
+
+	//all of the missing variables are found (once) before anything is upgraded
+	expected := "subnets"
+	if strings.Join(missing, ",") != expected {
+		t.Errorf("expected: %s; actual: %s", expected, strings.Join(missing, ","))
+	}
+	if len(getMissingAnswers(modules, map[string]string{"vpc": "vpc-123", "subnets": "subnet-1"})) != 0 {
+		t.Error("not expecting missing answers")
+	}
+}
+
+func TestGetUpgradeEnvironments(t *testing.T) {
+	teardown := setupTestCase(t)
+	defer teardown(t)
+	targetDir = tmpDir
+	defer func() {
+		targetDir = targetInfrastructureDir
+		upgradeOnly = ""
+		upgradeEnvs = nil
+		upgradeExcludeEnvs = nil
+	}()
+
+	//dev and prod have var files (hcl and json), scratch doesn't
+	writeTestFiles(t, tmpDir, map[string]string{
+		"base/main.tf":                   "",
+		"env/dev/terraform.tfvars":       "",
+		"env/prod/terraform.tfvars.json": "{}",
+		"env/scratch/main.tf":            "",
+		"env/README.md":                  "",
+	})
+
+	tests := []struct {
+		name     string
+		only     string
+		envs     []string
+		exclude  []string
+		expected string
+		err      bool
+	}{
+		{name: "all", expected: "dev,prod"},
+		{name: "only env", only: upgradeOnlyEnv, expected: "dev,prod"},
+		{name: "only base", only: upgradeOnlyBase, expected: ""},
+		{name: "env", envs: []string{"prod"}, expected: "prod"},
+		{name: "exclude env", exclude: []string{"dev"}, expected: "prod"},
+		{name: "unknown env", envs: []string{"qa"}, err: true},
+		{name: "unknown exclude env", exclude: []string{"qa"}, err: true},
+		{name: "env without var file", envs: []string{"scratch"}, err: true},
+		{name: "base", envs: []string{"base"}, err: true},
+	}
+	for _, test := range tests {
+		upgradeOnly = test.only
+		upgradeEnvs = test.envs
+		upgradeExcludeEnvs = test.exclude
+
+		environments, err := getUpgradeEnvironments()
+
+		if test.err {
+			if err == nil {
+				t.Error(test.name, "expecting error")
+			}
+			continue
+		}
+		if err != nil {
+			t.Error(test.name, err)
+		}
+		if strings.Join(environments, ",") != test.expected {
+			t.Errorf("%s expected: %s; actual: %s", test.name, test.expected, strings.Join(environments, ","))
+		}
+	}
+}
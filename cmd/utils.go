@@ -21,6 +21,7 @@ func check(e error) {
 
 		//clean up before exiting
 		os.RemoveAll(tempDir)
+		os.RemoveAll(stagingDir)
 
 		log.Fatal("ERROR: ", e)
 	}
@@ -219,3 +220,31 @@ func promptAndGetResponse(question string, defaultResponse string) string {
 	}
 	return response
 }
+
+//promptAndGetLine prompts for a value that may contain spaces and returns the trimmed line
+func promptAndGetLine(question string) string {
+	fmt.Print(question)
+
+	//read a byte at a time so that nothing is buffered past the newline
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		n, err := os.Stdin.Read(b)
+		if n == 1 {
+			if b[0] == '\n' {
+				break
+			}
+			line = append(line, b[0])
+		}
+		if err == io.EOF {
+			if len(line) == 0 {
+				log.Fatal("unexpected end of input")
+			}
+			break
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	return strings.TrimSpace(string(line))
+}
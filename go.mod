@@ -7,6 +7,7 @@ require (
 	github.com/hashicorp/go-getter v1.8.6
 	github.com/spf13/cobra v1.4.0
 	gopkg.in/yaml.v2 v2.4.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (